@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/cli"
+	"github.com/vapourlang/vapour/collate"
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/manifest"
+	"github.com/vapourlang/vapour/rsource"
+	"github.com/vapourlang/vapour/token"
+)
+
+// build of -indir, the files unchanged since the last
+// build, according to its manifest, are not checked again
+type build struct {
+	path      string
+	key       string
+	manifest  *manifest.Manifest
+	files     map[string]manifest.File
+	unchanged map[string]bool
+}
+
+func (v *vapour) newBuild(conf cli.CLI, prog *ast.Program, items token.Items) *build {
+	settings, _ := json.Marshal(v.config)
+
+	key := v.version + "\n" + string(settings) + "\n" + rSources(*conf.Outdir)
+
+	b := &build{
+		key:       manifest.Hash([]byte(key)),
+		manifest:  &manifest.Manifest{Files: make(map[string]manifest.File)},
+		files:     make(map[string]manifest.File),
+		unchanged: make(map[string]bool),
+	}
+
+	declarations := manifest.Declarations(items)
+	dependencies := collate.Dependencies(prog, items)
+
+	for _, f := range v.files {
+		b.files[f.Path] = manifest.File{
+			Hash:         manifest.Hash(f.Content),
+			Declarations: declarations[f.Path],
+			Dependencies: dependencies[f.Path],
+		}
+	}
+
+	path, err := manifest.Path(*conf.Indir, *conf.Outdir)
+
+	// without a cache directory every build is complete
+	if err != nil {
+		return b
+	}
+
+	b.path = path
+
+	if *conf.Rebuild {
+		return b
+	}
+
+	b.manifest = manifest.Read(path)
+	b.unchanged = b.manifest.Unchanged(b.key, b.files)
+
+	return b
+}
+
+// diagnostics returns those of the checks and those
+// recorded for the files that were not checked
+func (b *build) diagnostics(ds diagnostics.Diagnostics) diagnostics.Diagnostics {
+
+	for path := range b.unchanged {
+		ds = append(ds, b.manifest.Files[path].Diagnostics...)
+	}
+
+	seen := make(map[string]bool)
+	var unique diagnostics.Diagnostics
+	for _, d := range ds {
+		key := fmt.Sprintf("%v:%v:%v %v", d.Token.File, d.Token.Line, d.Token.Char, d.Message)
+
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		unique = append(unique, d)
+	}
+
+	return unique
+}
+
+// save records the files built, the diagnostics of
+// their statements and the outputs generated from them
+func (b *build) save(statements diagnostics.Diagnostics, outputs map[string][]string) {
+	if b.path == "" {
+		return
+	}
+
+	m := &manifest.Manifest{
+		Key:   b.key,
+		Files: make(map[string]manifest.File),
+	}
+
+	for path, f := range b.files {
+		f.Outputs = outputs[path]
+
+		if b.unchanged[path] {
+			f.Diagnostics = b.manifest.Files[path].Diagnostics
+		}
+
+		m.Files[path] = f
+	}
+
+	for _, d := range statements {
+		f, ok := m.Files[d.Token.File]
+
+		if !ok || b.unchanged[d.Token.File] {
+			continue
+		}
+
+		f.Diagnostics = append(f.Diagnostics, d)
+		m.Files[d.Token.File] = f
+	}
+
+	err := m.Write(b.path)
+
+	// the next build is complete
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the build manifest: %v\n", err.Error())
+	}
+}
+
+// rSources returns the hashes of the hand-written R files
+// of outdir, the functions they define are checked against
+func rSources(outdir string) string {
+	files, _ := filepath.Glob(filepath.Join(outdir, "*.[Rr]"))
+
+	var hashes []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+
+		if err != nil || rsource.IsGenerated(string(content)) {
+			continue
+		}
+
+		hashes = append(hashes, file+" "+manifest.Hash(content))
+	}
+
+	return strings.Join(hashes, "\n")
+}
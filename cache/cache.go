@@ -1,21 +1,39 @@
 package cache
 
-var cache = make(map[string]interface{})
+import "sync"
+
+var (
+	cache = make(map[string]interface{})
+	// functions are checked concurrently
+	mu sync.RWMutex
+)
 
 func Set(key string, value interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
 	cache[key] = value
 }
 
 func Get(key string) (interface{}, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	v, ok := cache[key]
 	return v, ok
 }
 
 func Has(key string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	_, ok := cache[key]
 	return ok
 }
 
 func Clear() {
+	mu.Lock()
+	defer mu.Unlock()
+
 	cache = make(map[string]interface{})
 }
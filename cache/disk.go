@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type entry struct {
+	// package the entry depends on, if any
+	Package string `json:"package,omitempty"`
+	// version and modification time of its DESCRIPTION
+	Stamp string          `json:"stamp,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+type store struct {
+	file    string
+	libs    []string
+	entries map[string]entry
+	// entries changed since the last write
+	dirty bool
+	mu    sync.Mutex
+}
+
+// disk is nil until Open is called:
+// the cache then only lives in memory
+var disk *store
+
+// Dir returns the directory in which the cache is stored
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "vapour"), nil
+}
+
+// Open loads the cache of the R installation identified by its
+// version and library paths, entries are invalidated when the
+// DESCRIPTION of the package they depend on changes
+func Open(version string, libs []string) error {
+	dir, err := Dir()
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256([]byte(version + "\n" + strings.Join(libs, "\n")))
+
+	s := &store{
+		file:    filepath.Join(dir, hex.EncodeToString(hash[:8])+".json"),
+		libs:    libs,
+		entries: make(map[string]entry),
+	}
+
+	data, err := os.ReadFile(s.file)
+
+	if err == nil {
+		// a corrupted cache is ignored and overwritten
+		json.Unmarshal(data, &s.entries)
+	}
+
+	disk = s
+
+	return nil
+}
+
+// Store records the value under key, pkg ties the entry
+// to the package, it may be empty, it is written by Flush
+func Store(key, pkg string, value interface{}) {
+	if disk == nil {
+		return
+	}
+
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return
+	}
+
+	e := entry{Value: data}
+
+	if pkg != "" {
+		e.Package = pkg
+		e.Stamp = disk.stamp(pkg)
+	}
+
+	disk.mu.Lock()
+	defer disk.mu.Unlock()
+
+	disk.entries[key] = e
+	disk.dirty = true
+}
+
+// Flush writes the entries stored since the last call,
+// once per run rather than once per entry
+func Flush() {
+	if disk == nil {
+		return
+	}
+
+	disk.mu.Lock()
+	defer disk.mu.Unlock()
+
+	if !disk.dirty {
+		return
+	}
+
+	disk.save()
+	disk.dirty = false
+}
+
+// Load reads the value stored under key into value,
+// it returns false if there is none or if it is stale
+func Load(key string, value interface{}) bool {
+	if disk == nil {
+		return false
+	}
+
+	disk.mu.Lock()
+	e, ok := disk.entries[key]
+	disk.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	if e.Package != "" && e.Stamp != disk.stamp(e.Package) {
+		disk.mu.Lock()
+		delete(disk.entries, key)
+		disk.dirty = true
+		disk.mu.Unlock()
+		return false
+	}
+
+	return json.Unmarshal(e.Value, value) == nil
+}
+
+func (s *store) save() {
+	data, err := json.Marshal(s.entries)
+
+	if err != nil {
+		return
+	}
+
+	// write then rename so concurrent runs never read half a file
+	tmp := s.file + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+
+	if err != nil {
+		return
+	}
+
+	os.Rename(tmp, s.file)
+}
+
+// stamp identifies the installed version of a package,
+// from the first library in which it is found
+func (s *store) stamp(pkg string) string {
+	for _, lib := range s.libs {
+		p := filepath.Join(lib, pkg, "DESCRIPTION")
+		info, err := os.Stat(p)
+
+		if err != nil {
+			continue
+		}
+
+		return fmt.Sprintf("%v@%v", descriptionVersion(p), info.ModTime().UnixNano())
+	}
+
+	return "missing"
+}
+
+func descriptionVersion(file string) string {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return ""
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Version:"))
+		}
+	}
+
+	return ""
+}
@@ -16,6 +16,9 @@ var Gray = "\033[37m"
 var White = "\033[97m"
 var Bold = "\033[1m"
 
+// Clear clears the terminal
+var Clear = "\033[H\033[2J"
+
 func init() {
 	if runtime.GOOS == "windows" {
 		Reset = ""
@@ -27,24 +30,36 @@ func init() {
 		Cyan = ""
 		Gray = ""
 		White = ""
+		Clear = ""
 	}
 }
 
 type CLI struct {
-	Indir    *string
-	Outdir   *string
-	LSP      *bool
-	TCP      *bool
-	Port     *string
-	Repl     *bool
-	Help     *bool
-	Version  *bool
-	Check    *bool
-	Run      *bool
-	Types    *string
-	Infile   *string
-	Outfile  *string
-	Devtools *string
+	Indir     *string
+	Outdir    *string
+	LSP       *bool
+	TCP       *bool
+	Port      *string
+	Repl      *bool
+	Help      *bool
+	Version   *bool
+	Check     *bool
+	Run       *bool
+	Types     *string
+	Infile    *string
+	Outfile   *string
+	Devtools  *string
+	Offline   *bool
+	Stub      *string
+	Migrate   *string
+	Fmt       *string
+	FmtCheck  *bool
+	FmtWrite  *bool
+	Traceback *string
+	Split     *bool
+	Collate   *bool
+	Rebuild   *bool
+	Watch     *bool
 }
 
 func Cli() CLI {
@@ -54,6 +69,16 @@ func Cli() CLI {
 	infile := flag.String("infile", "", "Vapour file to process")
 	outfile := flag.String("outfile", "vapour.R", "Name of R file to where to palce transpiled `infile`. (defaults to vapour.R)")
 
+	// one output per input
+	split := flag.Bool("split", false, "Write one R file per vapour file of -indir in -outdir (e.g.: R/models.vp to R/models.R) instead of -outfile, generated files whose source was deleted are removed")
+	collate := flag.Bool("collate", false, "With -split, write the Collate field of the DESCRIPTION from the dependencies between files")
+
+	// incremental builds
+	rebuild := flag.Bool("rebuild", false, "Check and transpile all the files of -indir, by default files unchanged since the last build, along with the declarations they use, are not checked again")
+
+	// watch
+	watch := flag.Bool("watch", false, "Check and transpile -indir again whenever its vapour files change, until stopped")
+
 	// types
 	types := flag.String("types", "inst/types.vp", "Path where to generate the type files, only applies if passing a directory with -indir")
 
@@ -77,21 +102,49 @@ func Cli() CLI {
 	// devtools
 	devtools := flag.String("devtools", "", "Run {devtools} functions after transpilation, accepts `document`, `check`, `install`, separate by comma (e.g.: `document,check`)")
 
+	// stub
+	stub := flag.String("stub", "", "Write declarations of the exported functions of an installed package to types/<package>.vp")
+
+	// migrate
+	migrate := flag.String("migrate", "", "Convert an R file, or the R files of a directory, to vapour files written alongside")
+
+	// format
+	format := flag.String("fmt", "", "Format a vapour file, or the vapour files of a directory, prints the result unless -fmt-check or -w is passed")
+	formatCheck := flag.Bool("fmt-check", false, "With -fmt, list the files that are not formatted and exit with a non-zero status")
+	formatWrite := flag.Bool("w", false, "With -fmt, write the result to the files")
+
+	// source maps
+	traceback := flag.String("traceback", "", "Rewrite the locations in generated R files found in R output, read from a file or - for stdin, to the vapour files, uses the source maps written next to the generated files")
+
+	// offline
+	offline := flag.Bool("offline", false, "Do not query R, checks that need it are skipped (defaults to true when R is not found)")
+
 	flag.Parse()
 
 	return CLI{
-		Indir:    indir,
-		Outdir:   outdir,
-		LSP:      lsp,
-		TCP:      tcp,
-		Port:     port,
-		Infile:   infile,
-		Outfile:  outfile,
-		Repl:     repl,
-		Check:    check,
-		Run:      run,
-		Version:  version,
-		Types:    types,
-		Devtools: devtools,
+		Indir:     indir,
+		Outdir:    outdir,
+		LSP:       lsp,
+		TCP:       tcp,
+		Port:      port,
+		Infile:    infile,
+		Outfile:   outfile,
+		Repl:      repl,
+		Check:     check,
+		Run:       run,
+		Version:   version,
+		Types:     types,
+		Devtools:  devtools,
+		Offline:   offline,
+		Stub:      stub,
+		Migrate:   migrate,
+		Fmt:       format,
+		FmtCheck:  formatCheck,
+		FmtWrite:  formatWrite,
+		Traceback: traceback,
+		Split:     split,
+		Collate:   collate,
+		Rebuild:   rebuild,
+		Watch:     watch,
 	}
 }
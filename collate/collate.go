@@ -0,0 +1,163 @@
+package collate
+
+import (
+	"os"
+	"strings"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/token"
+)
+
+// Dependencies returns, for each vapour file, the files defining
+// the functions, variables and types it uses at the top level
+func Dependencies(prog *ast.Program, items token.Items) map[string][]string {
+	defined := make(map[string]string)
+
+	for _, s := range prog.Statements {
+		for _, name := range definitions(s) {
+			if _, ok := defined[name]; !ok {
+				defined[name] = s.Item().File
+			}
+		}
+	}
+
+	deps := make(map[string][]string)
+	seen := make(map[string]bool)
+
+	for _, it := range items {
+		if it.Class != token.ItemIdent && it.Class != token.ItemTypes {
+			continue
+		}
+
+		file, ok := defined[it.Value]
+
+		if !ok || file == it.File || seen[it.File+"\n"+file] {
+			continue
+		}
+
+		seen[it.File+"\n"+file] = true
+		deps[it.File] = append(deps[it.File], file)
+	}
+
+	return deps
+}
+
+// definitions returns the names the statement defines
+func definitions(s ast.Statement) []string {
+	switch n := s.(type) {
+	case *ast.LetStatement:
+		return []string{n.Name}
+	case *ast.ConstStatement:
+		return []string{n.Name}
+	case *ast.TypeStatement:
+		return []string{n.Name}
+	case *ast.ExpressionStatement:
+		return expressionDefinitions(n.Expression)
+	}
+
+	return nil
+}
+
+func expressionDefinitions(e ast.Expression) []string {
+	switch n := e.(type) {
+	case *ast.FunctionLiteral:
+		// methods are found through their generic
+		if n.Name == "" || n.Method != nil {
+			return nil
+		}
+		return []string{n.Name}
+	case *ast.DecoratorGeneric:
+		return expressionDefinitions(n.Func)
+	case *ast.DecoratorDefault:
+		return expressionDefinitions(n.Func)
+	case *ast.DecoratorClass:
+		return []string{n.Type.Name}
+	case *ast.DecoratorEnvironment:
+		return []string{n.Type.Name}
+	case *ast.DecoratorFactor:
+		return []string{n.Type.Name}
+	case *ast.DecoratorMatrix:
+		return []string{n.Type.Name}
+	}
+
+	return nil
+}
+
+// Order sorts the files so each comes after the files it
+// depends on, files are otherwise kept in the order given,
+// cycles are broken in that order
+func Order(files []string, deps map[string][]string) []string {
+	known := make(map[string]bool)
+	for _, f := range files {
+		known[f] = true
+	}
+
+	placed := make(map[string]bool)
+	var ordered []string
+
+	for len(ordered) < len(files) {
+		next := ""
+		for _, f := range files {
+			if placed[f] {
+				continue
+			}
+
+			if next == "" {
+				next = f
+			}
+
+			if ready(f, deps[f], known, placed) {
+				next = f
+				break
+			}
+		}
+
+		placed[next] = true
+		ordered = append(ordered, next)
+	}
+
+	return ordered
+}
+
+func ready(file string, deps []string, known, placed map[string]bool) bool {
+	for _, d := range deps {
+		if d != file && known[d] && !placed[d] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Write sets the Collate field of the DESCRIPTION file
+func Write(description string, files []string) error {
+	content, err := os.ReadFile(description)
+
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	inCollate := false
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		// fields continue on indented lines
+		if inCollate && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			continue
+		}
+
+		inCollate = strings.HasPrefix(line, "Collate:")
+
+		if inCollate {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "Collate:")
+	for _, f := range files {
+		lines = append(lines, "    '"+f+"'")
+	}
+
+	return os.WriteFile(description, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,137 @@
+package collate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vapourlang/vapour/lexer"
+	"github.com/vapourlang/vapour/parser"
+)
+
+func TestDependencies(t *testing.T) {
+	files := lexer.Files{
+		{
+			Path: "R/api.vp",
+			Content: []byte(`func get(p: person): char {
+  return format_name(p$name)
+}
+`),
+		},
+		{
+			Path: "R/utils.vp",
+			Content: []byte(`func format_name(x: char): char {
+  return toupper(x)
+}
+`),
+		},
+		{
+			Path: "R/types.vp",
+			Content: []byte(`type person: object {
+  name: char
+}
+`),
+		},
+	}
+
+	l := lexer.New(files)
+	l.Run()
+
+	p := parser.New(l)
+	prog := p.Run()
+
+	if p.HasError() {
+		t.Fatal(p.Errors())
+	}
+
+	deps := Dependencies(prog, l.Items)
+
+	expected := map[string][]string{
+		"R/api.vp": {"R/types.vp", "R/utils.vp"},
+	}
+
+	if !reflect.DeepEqual(deps, expected) {
+		t.Fatalf("expected %v, got %v", expected, deps)
+	}
+
+	order := Order([]string{"R/api.vp", "R/types.vp", "R/utils.vp"}, deps)
+
+	if !reflect.DeepEqual(order, []string{"R/types.vp", "R/utils.vp", "R/api.vp"}) {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	files := []string{"a.R", "b.R", "c.R", "d.R"}
+
+	tests := []struct {
+		deps     map[string][]string
+		expected []string
+	}{
+		{
+			deps:     map[string][]string{},
+			expected: []string{"a.R", "b.R", "c.R", "d.R"},
+		},
+		{
+			deps:     map[string][]string{"a.R": {"d.R"}},
+			expected: []string{"b.R", "c.R", "d.R", "a.R"},
+		},
+		// cycles are broken in the given order
+		{
+			deps:     map[string][]string{"a.R": {"b.R"}, "b.R": {"a.R"}},
+			expected: []string{"c.R", "d.R", "a.R", "b.R"},
+		},
+		// unknown files are ignored
+		{
+			deps:     map[string][]string{"a.R": {"z.R"}},
+			expected: []string{"a.R", "b.R", "c.R", "d.R"},
+		},
+	}
+
+	for _, test := range tests {
+		got := Order(files, test.deps)
+
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("expected %v, got %v", test.expected, got)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	description := filepath.Join(t.TempDir(), "DESCRIPTION")
+
+	content := `Package: test
+Version: 0.0.1
+Collate:
+    'old.R'
+    'older.R'
+Imports: stats
+`
+
+	err := os.WriteFile(description, []byte(content), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Write(description, []string{"types.R", "api.R"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := os.ReadFile(description)
+
+	expected := `Package: test
+Version: 0.0.1
+Imports: stats
+Collate:
+    'types.R'
+    'api.R'
+`
+
+	if string(got) != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, string(got))
+	}
+}
@@ -0,0 +1,198 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/collate"
+	"github.com/vapourlang/vapour/config"
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/lexer"
+	"github.com/vapourlang/vapour/parser"
+	"github.com/vapourlang/vapour/rsource"
+	"github.com/vapourlang/vapour/sourcemap"
+	"github.com/vapourlang/vapour/token"
+	"github.com/vapourlang/vapour/transpiler"
+	"github.com/vapourlang/vapour/walker"
+)
+
+// Header is at the top of the generated code,
+// it marks the R files vapour may overwrite
+const Header = rsource.Generated + "\n# DO NOT EDIT\n"
+
+// Options of a check or a build, the zero value is valid
+type Options struct {
+	// optional diagnostics, the defaults if nil
+	Config *config.Config
+	// global environment the code is checked against, it is copied
+	// so it can be kept between builds, if nil one is created,
+	// which starts R unless it is not available
+	Environment *environment.Environment
+	// directory of the hand-written R files whose functions
+	// the code calls, e.g. R in a package, none if empty
+	RSource string
+	// Skip returns the files whose statements are not checked,
+	// e.g. those unchanged since the last build, see walker.Skip
+	Skip func(prog *ast.Program, items token.Items) map[string]bool
+	// generate the code of each vapour file on its own
+	Split bool
+}
+
+// Result of the checks
+type Result struct {
+	// program of all the files, nil if they could not be parsed
+	Program *ast.Program
+	// tokens of all the files
+	Items token.Items
+	// declarations of the program, nil if it could not be parsed
+	Environment *environment.Environment
+	// errors of lexing and parsing, or the diagnostics of the checks
+	Diagnostics diagnostics.Diagnostics
+	// diagnostics of the statements, those on the
+	// whole program, e.g. unused functions, aside
+	Statements diagnostics.Diagnostics
+	// files whose statements were not checked
+	Skipped map[string]bool
+}
+
+// File is the code generated from a vapour file
+type File struct {
+	Path string
+	Code string
+	// File of the map is empty: it is where the code is written
+	SourceMap sourcemap.Map
+}
+
+// Output of a build
+type Output struct {
+	Result
+	// code of all the files, empty if the checks failed
+	Code      string
+	SourceMap sourcemap.Map
+	// code of each file with Options.Split, in the order
+	// in which R must source them: after their dependencies
+	Files []File
+	// files each file uses the declarations of
+	Dependencies map[string][]string
+	// declarations of the code for other packages,
+	// see environment.LoadPackageTypes
+	Types string
+}
+
+// HasError reports whether the files could not be parsed
+// or the checks found errors, hints and infos aside
+func (r Result) HasError() bool {
+	for _, d := range r.Diagnostics {
+		if d.Severity != diagnostics.Info && d.Severity != diagnostics.Hint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check lexes, parses and checks the files, nothing is printed
+func Check(files lexer.Files, opts Options) Result {
+	var res Result
+
+	// lex and parse each file concurrently
+	parsed := parser.ParseFiles(files)
+
+	if parsed.HasError() {
+		res.Diagnostics = parsed.Errors()
+		return res
+	}
+
+	// declarations of all files are checked together
+	res.Program, res.Items = parsed.Merge()
+
+	env := opts.Environment
+	if env == nil {
+		env = environment.NewGlobalEnvironment()
+	} else {
+		env = env.Copy()
+	}
+
+	w := walker.NewWithEnvironment(env)
+	w.Configure(opts.Config)
+
+	if opts.RSource != "" {
+		w.Env().LoadRSource(opts.RSource)
+	}
+
+	if opts.Skip != nil {
+		res.Skipped = opts.Skip(res.Program, res.Items)
+		w.Skip(res.Skipped, res.Items)
+	}
+
+	w.Walk(res.Program)
+
+	res.Environment = w.Env()
+	// those of the environment first, e.g. R could not be queried
+	res.Diagnostics = append(env.Diagnostics(), w.Errors()...)
+	res.Statements = w.StatementErrors()
+
+	return res
+}
+
+// Build checks the files and, if the checks
+// pass, generates their R code, nothing is written
+func Build(files lexer.Files, opts Options) Output {
+	res := Check(files, opts)
+	out := Output{Result: res}
+
+	if res.Program == nil || res.HasError() {
+		return out
+	}
+
+	trans := transpiler.New()
+	trans.Transpile(res.Program)
+	out.Code, out.SourceMap = generated(trans)
+
+	out.Types = res.Environment.GenerateTypes().String()
+
+	if !opts.Split {
+		return out
+	}
+
+	out.Dependencies = collate.Dependencies(res.Program, res.Items)
+
+	statements := make(map[string][]ast.Statement)
+
+	file := ""
+	for _, s := range res.Program.Statements {
+		if f := s.Item().File; f != "" {
+			file = f
+		}
+
+		statements[file] = append(statements[file], s)
+	}
+
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+
+	// declarations are kept from one file to the next,
+	// files are transpiled after those they depend on
+	trans = transpiler.New()
+	for _, path := range collate.Order(paths, out.Dependencies) {
+		trans.Reset()
+		trans.Transpile(&ast.Program{Statements: statements[path]})
+
+		f := File{Path: path}
+		f.Code, f.SourceMap = generated(trans)
+		out.Files = append(out.Files, f)
+	}
+
+	return out
+}
+
+// generated returns the code with its header and its source map
+func generated(trans *transpiler.Transpiler) (string, sourcemap.Map) {
+	m := trans.SourceMap("")
+	m.Shift(strings.Count(Header, "\n"))
+
+	return Header + trans.GetCode(), m
+}
@@ -0,0 +1,83 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/lexer"
+)
+
+func TestCheck(t *testing.T) {
+	// parsing error
+	res := Check(lexer.Files{
+		{Path: "a.vp", Content: []byte(`let x: int = "a`)},
+	}, Options{})
+
+	if res.Program != nil || !res.HasError() {
+		t.Fatalf("expected a parsing error, got %v", res.Diagnostics)
+	}
+
+	// type error
+	res = Check(lexer.Files{
+		{Path: "a.vp", Content: []byte(`let x: int = "a"`)},
+	}, Options{})
+
+	if res.Program == nil || !res.HasError() {
+		t.Fatalf("expected a type error, got %v", res.Diagnostics)
+	}
+
+	if res.Diagnostics[0].Token.File != "a.vp" {
+		t.Fatalf("expected the error in a.vp, got %v", res.Diagnostics[0])
+	}
+}
+
+func TestBuild(t *testing.T) {
+	files := lexer.Files{
+		{Path: "b.vp", Content: []byte(`#' @export
+func greet(x: char = "you"): char {
+  let p: person = person(name = x)
+  return paste("hello", p$name)
+}
+`)},
+		{Path: "a.vp", Content: []byte(`type person: object {
+  name: char
+}
+`)},
+	}
+
+	env := environment.NewGlobalEnvironment()
+
+	out := Build(files, Options{Environment: env, Split: true})
+
+	if out.HasError() {
+		t.Fatal(out.Diagnostics)
+	}
+
+	if !strings.HasPrefix(out.Code, Header) || !strings.Contains(out.Code, "greet = function(x") {
+		t.Fatalf("unexpected code:\n%v", out.Code)
+	}
+
+	// lines of the header are not mapped
+	if len(out.SourceMap.Lines) == 0 || out.SourceMap.Lines[0].Line <= strings.Count(Header, "\n") {
+		t.Fatalf("unexpected source map: %v", out.SourceMap)
+	}
+
+	// a.vp declares the type b.vp uses
+	if len(out.Files) != 2 || out.Files[0].Path != "a.vp" || out.Files[1].Path != "b.vp" {
+		t.Fatalf("expected a.vp then b.vp, got %v", out.Files)
+	}
+
+	if !strings.Contains(out.Types, "func greet(x: char") {
+		t.Fatalf("expected greet in the types, got:\n%v", out.Types)
+	}
+
+	// the environment given is copied
+	if _, ok := env.GetFunction("greet", false); ok {
+		t.Fatal("expected the environment of the options to be left unchanged")
+	}
+
+	if _, ok := out.Environment.GetFunction("greet", false); !ok {
+		t.Fatal("expected greet in the environment of the result")
+	}
+}
@@ -13,8 +13,26 @@ type lspConfig struct {
 	Severity []string `json:"severity"`
 }
 
+// optional diagnostics of the walker
+type lintConfig struct {
+	Shadow          bool `json:"shadow"`
+	UnusedParameter bool `json:"unusedParameter"`
+	UnusedFunction  bool `json:"unusedFunction"`
+}
+
+// R toolchain, the VAPOUR_R environment variable overrides the path
+type rConfig struct {
+	Path string `json:"path"`
+	// library paths used before those of R
+	Libs []string `json:"libs"`
+}
+
 type Config struct {
-	Lsp *lspConfig `json:"lsp"`
+	Lsp  *lspConfig  `json:"lsp"`
+	Lint *lintConfig `json:"lint"`
+	R    *rConfig    `json:"r"`
+	// do not query R, even if it is installed
+	Offline bool `json:"offline"`
 }
 
 func makeConfigPath(conf string) string {
@@ -39,13 +57,26 @@ func hasConfig(conf string) bool {
 	return !os.IsNotExist(err)
 }
 
-func ReadConfig() *Config {
-	configuration := &Config{
+// Default returns the configuration used
+// when the user has no configuration file
+func Default() *Config {
+	return &Config{
 		Lsp: &lspConfig{
 			When:     []string{"open", "save", "close", "text"},
 			Severity: []string{"fatal", "warn", "info", "hint"},
 		},
+		Lint: &lintConfig{
+			Shadow:          true,
+			UnusedParameter: true,
+			// packages export functions that are not called
+			UnusedFunction: false,
+		},
+		R: &rConfig{},
 	}
+}
+
+func ReadConfig() *Config {
+	configuration := Default()
 
 	if !hasConfig(file) {
 		return configuration
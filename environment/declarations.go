@@ -0,0 +1,93 @@
+package environment
+
+import (
+	"embed"
+	"path"
+	"strings"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/lexer"
+	"github.com/vapourlang/vapour/parser"
+	"github.com/vapourlang/vapour/token"
+)
+
+// typed signatures of base R functions,
+// each file is named after its package
+//
+//go:embed declarations/*.vp
+var declarations embed.FS
+
+// loadDeclarations registers the functions of the declaration
+// library, these replace the untyped functions listed from R
+func (e *Environment) loadDeclarations() {
+	files, err := declarations.ReadDir("declarations")
+
+	if err != nil {
+		return
+	}
+
+	for _, f := range files {
+		file := path.Join("declarations", f.Name())
+		prog, errs := parseDeclarations(file)
+
+		// the files are part of vapour, they must parse
+		if len(errs) > 0 {
+			e.diagnostics = append(e.diagnostics, errs...)
+			continue
+		}
+
+		pkg := strings.TrimSuffix(f.Name(), ".vp")
+
+		for _, s := range prog.Statements {
+			es, ok := s.(*ast.ExpressionStatement)
+
+			if !ok {
+				continue
+			}
+
+			fn, ok := es.Expression.(*ast.FunctionLiteral)
+
+			if !ok || fn.Name == "" {
+				continue
+			}
+
+			e.SetFunction(
+				fn.Name,
+				Function{
+					Token:   fn.Token,
+					Value:   fn,
+					Package: pkg,
+					Name:    fn.Name,
+				},
+			)
+		}
+	}
+}
+
+// parseDeclarations lexes and parses an embedded declarations file
+func parseDeclarations(file string) (*ast.Program, diagnostics.Diagnostics) {
+	content, err := declarations.ReadFile(file)
+
+	if err != nil {
+		return nil, diagnostics.Diagnostics{
+			diagnostics.NewError(token.Item{File: file}, err.Error()),
+		}
+	}
+
+	l := lexer.NewCode(file, string(content))
+	l.Run()
+
+	if l.HasError() {
+		return nil, l.Errors()
+	}
+
+	p := parser.New(l)
+	prog := p.Run()
+
+	if p.HasError() {
+		return nil, p.Errors()
+	}
+
+	return prog, nil
+}
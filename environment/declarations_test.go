@@ -0,0 +1,83 @@
+package environment
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/token"
+)
+
+func TestDeclarationFiles(t *testing.T) {
+	files, err := declarations.ReadDir("declarations")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("expected declaration files")
+	}
+
+	env := New()
+	env.loadDeclarations()
+
+	// problems are returned with the diagnostics of the checks
+	if len(env.Diagnostics()) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", env.Diagnostics())
+	}
+
+	for _, f := range files {
+		file := path.Join("declarations", f.Name())
+		prog, errs := parseDeclarations(file)
+
+		if len(errs) > 0 {
+			t.Fatalf("%v does not parse:\n%v", file, errs.String())
+		}
+
+		pkg := strings.TrimSuffix(f.Name(), ".vp")
+
+		n := 0
+		for _, s := range prog.Statements {
+			es, ok := s.(*ast.ExpressionStatement)
+
+			if !ok {
+				continue
+			}
+
+			fn, ok := es.Expression.(*ast.FunctionLiteral)
+
+			if !ok || fn.Name == "" {
+				continue
+			}
+
+			n++
+
+			registered, ok := env.GetFunction(fn.Name, false)
+
+			if !ok || registered.Package != pkg {
+				t.Fatalf("%v: expected `%v` to be registered for %v", file, fn.Name, pkg)
+			}
+		}
+
+		if n == 0 {
+			t.Fatalf("%v declares no function", file)
+		}
+	}
+}
+
+func TestCopyDiagnostics(t *testing.T) {
+	env := New()
+	env.diagnostics = diagnostics.Diagnostics{
+		diagnostics.NewInfo(token.Item{}, "failed to fetch base R functions"),
+	}
+
+	// -watch checks copies of the global environment
+	copied := env.Copy()
+
+	if len(copied.Diagnostics()) != 1 {
+		t.Fatalf("expected the diagnostics to be copied, got %v", copied.Diagnostics())
+	}
+}
@@ -1,10 +1,12 @@
 package environment
 
 import (
-	"fmt"
+	"sync"
 
 	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/diagnostics"
 	"github.com/vapourlang/vapour/r"
+	"github.com/vapourlang/vapour/token"
 )
 
 type Environment struct {
@@ -19,6 +21,15 @@ type Environment struct {
 	env        map[string]Env
 	returnType ast.Types
 	outer      *Environment
+	// packages whose types.vp was loaded
+	loaded map[string]bool
+	// problems met while building the global environment
+	diagnostics diagnostics.Diagnostics
+	// function bodies are checked concurrently
+	// against the same global environment
+	mu sync.RWMutex
+	// held while the types of a package are loaded
+	loading sync.Mutex
 }
 
 var library []string
@@ -96,6 +107,7 @@ func NewGlobalEnvironment() *Environment {
 		factor:    fct,
 		method:    meth,
 		outer:     nil,
+		loaded:    make(map[string]bool),
 	}
 
 	for _, t := range baseTypes {
@@ -105,8 +117,11 @@ func NewGlobalEnvironment() *Environment {
 	fns, err := r.ListBaseFunctions()
 
 	if err != nil {
-		fmt.Printf("failed to fetch base R functions: %v", err.Error())
-		return env
+		// we still have the functions of the snapshot
+		env.diagnostics = append(
+			env.diagnostics,
+			diagnostics.NewInfo(token.Item{}, "failed to fetch base R functions: "+err.Error()),
+		)
 	}
 
 	for _, pkg := range fns {
@@ -115,6 +130,8 @@ func NewGlobalEnvironment() *Environment {
 		}
 	}
 
+	env.loadDeclarations()
+
 	return env
 }
 
@@ -138,37 +155,90 @@ func New() *Environment {
 		factor:    fct,
 		method:    meth,
 		outer:     nil,
+		loaded:    make(map[string]bool),
 	}
 }
 
+// Copy returns an environment with the declarations of e that can
+// be modified without affecting e, e.g. to check the code against
+// a global environment built once
+func (e *Environment) Copy() *Environment {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	env := &Environment{
+		variables:   copyMap(e.variables),
+		types:       copyMap(e.types),
+		functions:   copyMap(e.functions),
+		class:       copyMap(e.class),
+		matrix:      copyMap(e.matrix),
+		factor:      copyMap(e.factor),
+		signature:   copyMap(e.signature),
+		method:      make(map[string]Methods),
+		env:         copyMap(e.env),
+		returnType:  e.returnType,
+		outer:       e.outer,
+		loaded:      copyMap(e.loaded),
+		diagnostics: e.diagnostics,
+	}
+
+	// methods are appended to
+	for k, v := range e.method {
+		env.method[k] = append(Methods{}, v...)
+	}
+
+	return env
+}
+
+func copyMap[T any](m map[string]T) map[string]T {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]T, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
+
 func (e *Environment) SetSignatureUsed(name string) (Signature, bool) {
+	e.mu.Lock()
 	obj, ok := e.signature[name]
 
 	if !ok && e.outer != nil {
+		e.mu.Unlock()
 		return e.outer.SetSignatureUsed(name)
 	}
 
 	obj.Used = true
 	e.signature[name] = obj
+	e.mu.Unlock()
 
 	return obj, ok
 }
 
 func (e *Environment) SetTypeUsed(pkg, name string) (Type, bool) {
+	e.mu.Lock()
 	obj, ok := e.types[makeTypeKey(pkg, name)]
 
 	if !ok && e.outer != nil {
+		e.mu.Unlock()
 		return e.outer.SetTypeUsed(pkg, name)
 	}
 
 	obj.Used = true
 	e.types[name] = obj
+	e.mu.Unlock()
 
 	return obj, ok
 }
 
 func (e *Environment) GetVariable(name string, outer bool) (Variable, bool) {
+	e.mu.RLock()
 	obj, ok := e.variables[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil && outer {
 		obj, ok = e.outer.GetVariable(name, outer)
 	}
@@ -185,20 +255,44 @@ func (e *Environment) GetVariableParent(name string) (Variable, bool) {
 	return obj, ok
 }
 
+// GetVariableEnvironment returns the environment in which
+// the variable is declared
+func (e *Environment) GetVariableEnvironment(name string) (*Environment, bool) {
+	e.mu.RLock()
+	_, ok := e.variables[name]
+	e.mu.RUnlock()
+
+	if ok {
+		return e, true
+	}
+
+	if e.outer == nil {
+		return nil, false
+	}
+
+	return e.outer.GetVariableEnvironment(name)
+}
+
 func (e *Environment) SetVariable(name string, val Variable) Variable {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	e.variables[name] = val
 	return val
 }
 
 func (e *Environment) SetVariableUsed(name string) (Variable, bool) {
+	e.mu.Lock()
 	obj, ok := e.variables[name]
 
 	if !ok && e.outer != nil {
+		e.mu.Unlock()
 		return e.outer.SetVariableUsed(name)
 	}
 
 	obj.Used = true
 	e.variables[name] = obj
+	e.mu.Unlock()
 
 	return obj, ok
 }
@@ -224,7 +318,9 @@ func makeTypeKey(pkg, name string) string {
 
 func (e *Environment) GetType(pkg, name string) (Type, bool) {
 	e.LoadPackageTypes(pkg)
+	e.mu.RLock()
 	obj, ok := e.types[makeTypeKey(pkg, name)]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetType(pkg, name)
 	}
@@ -237,12 +333,17 @@ func (e *Environment) GetType(pkg, name string) (Type, bool) {
 }
 
 func (e *Environment) SetType(val Type) Type {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	e.types[makeTypeKey(val.Package, val.Name)] = val
 	return val
 }
 
 func (e *Environment) GetFunction(name string, outer bool) (Function, bool) {
+	e.mu.RLock()
 	obj, ok := e.functions[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil && outer {
 		obj, ok = e.outer.GetFunction(name, outer)
 	}
@@ -250,12 +351,35 @@ func (e *Environment) GetFunction(name string, outer bool) (Function, bool) {
 }
 
 func (e *Environment) SetFunction(name string, val Function) Function {
+	e.mu.Lock()
 	e.functions[name] = val
+	e.mu.Unlock()
+
 	return val
 }
 
+func (e *Environment) SetFunctionUsed(name string) (Function, bool) {
+	e.mu.Lock()
+	obj, ok := e.functions[name]
+
+	if !ok && e.outer != nil {
+		e.mu.Unlock()
+		return e.outer.SetFunctionUsed(name)
+	}
+
+	if ok {
+		obj.Used = true
+		e.functions[name] = obj
+	}
+	e.mu.Unlock()
+
+	return obj, ok
+}
+
 func (e *Environment) GetClass(name string) (Class, bool) {
+	e.mu.RLock()
 	obj, ok := e.class[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetClass(name)
 	}
@@ -263,12 +387,17 @@ func (e *Environment) GetClass(name string) (Class, bool) {
 }
 
 func (e *Environment) SetClass(name string, val Class) Class {
+	e.mu.Lock()
 	e.class[name] = val
+	e.mu.Unlock()
+
 	return val
 }
 
 func (e *Environment) GetEnv(name string) (Env, bool) {
+	e.mu.RLock()
 	obj, ok := e.env[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetEnv(name)
 	}
@@ -276,12 +405,17 @@ func (e *Environment) GetEnv(name string) (Env, bool) {
 }
 
 func (e *Environment) SetEnv(name string, val Env) Env {
+	e.mu.Lock()
 	e.env[name] = val
+	e.mu.Unlock()
+
 	return val
 }
 
 func (e *Environment) GetFactor(name string) (Factor, bool) {
+	e.mu.RLock()
 	obj, ok := e.factor[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetFactor(name)
 	}
@@ -289,12 +423,17 @@ func (e *Environment) GetFactor(name string) (Factor, bool) {
 }
 
 func (e *Environment) SetFactor(name string, val Factor) Factor {
+	e.mu.Lock()
 	e.factor[name] = val
+	e.mu.Unlock()
+
 	return val
 }
 
 func (e *Environment) GetSignature(name string) (Signature, bool) {
+	e.mu.RLock()
 	obj, ok := e.signature[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetSignature(name)
 	}
@@ -302,12 +441,17 @@ func (e *Environment) GetSignature(name string) (Signature, bool) {
 }
 
 func (e *Environment) SetSignature(name string, val Signature) Signature {
+	e.mu.Lock()
 	e.signature[name] = val
+	e.mu.Unlock()
+
 	return val
 }
 
 func (e *Environment) GetMatrix(name string) (Matrix, bool) {
+	e.mu.RLock()
 	obj, ok := e.matrix[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetMatrix(name)
 	}
@@ -315,17 +459,25 @@ func (e *Environment) GetMatrix(name string) (Matrix, bool) {
 }
 
 func (e *Environment) SetMatrix(name string, val Matrix) Matrix {
+	e.mu.Lock()
 	e.matrix[name] = val
+	e.mu.Unlock()
+
 	return val
 }
 
 func (e *Environment) AddMethod(name string, val Method) Method {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	e.method[name] = append(e.method[name], val)
 	return val
 }
 
 func (e *Environment) GetMethods(name string) (Methods, bool) {
+	e.mu.RLock()
 	obj, ok := e.method[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetMethods(name)
 	}
@@ -333,7 +485,9 @@ func (e *Environment) GetMethods(name string) (Methods, bool) {
 }
 
 func (e *Environment) GetMethod(name string, t *ast.Type) (Method, bool) {
+	e.mu.RLock()
 	obj, ok := e.method[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.GetMethods(name)
 	}
@@ -367,10 +521,28 @@ func (e *Environment) HasMethods(name string, t *ast.Type) bool {
 	return false
 }
 
+// Diagnostics returns the problems met while building
+// the global environment, e.g. R could not be queried
+func (e *Environment) Diagnostics() diagnostics.Diagnostics {
+	return append(diagnostics.Diagnostics{}, e.diagnostics...)
+}
+
+// Types, Variables and Functions return copies of the maps,
+// taken under the lock as bodies may be checked concurrently
 func (e *Environment) Types() map[string]Type {
-	return e.types
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return copyMap(e.types)
 }
 
 func (e *Environment) Variables() map[string]Variable {
-	return e.variables
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return copyMap(e.variables)
+}
+
+func (e *Environment) Functions() map[string]Function {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return copyMap(e.functions)
 }
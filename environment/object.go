@@ -7,29 +7,41 @@ import (
 
 // this should be an interface but I haven't got the time right now
 type Function struct {
-	Token   token.Item
-	Package string
-	Value   *ast.FunctionLiteral
-	Name    string
+	Token    token.Item
+	Package  string
+	Value    *ast.FunctionLiteral
+	Name     string
+	Used     bool
+	Exported bool
+}
+
+// HasSignature checks whether the parameters of the function
+// are known, functions listed from R have an empty literal
+func (f Function) HasSignature() bool {
+	return f.Value != nil && f.Value.Name != ""
 }
 
 type Methods []Method
 
 type Method struct {
-	Token   token.Item
-	Package string
-	Value   *ast.FunctionLiteral
-	Name    string
+	Token    token.Item
+	Package  string
+	Value    *ast.FunctionLiteral
+	Name     string
+	Exported bool
 }
 
 type Variable struct {
 	Token    token.Item
 	Value    ast.Types
 	HasValue bool
-	CanMiss  bool
-	IsConst  bool
-	Used     bool
-	Name     string
+	// assigned on some but not all paths
+	MaybeValue bool
+	CanMiss    bool
+	IsConst    bool
+	IsParam    bool
+	Used       bool
+	Name       string
 }
 
 type Type struct {
@@ -0,0 +1,96 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/rsource"
+	"github.com/vapourlang/vapour/token"
+)
+
+// LoadRSource registers the top-level functions of the
+// hand-written .R files of dir, their parameters are `any`,
+// files generated by vapour are skipped
+func (e *Environment) LoadRSource(dir string) error {
+	files, err := os.ReadDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.EqualFold(filepath.Ext(f.Name()), ".R") {
+			continue
+		}
+
+		file := filepath.Join(dir, f.Name())
+		content, err := os.ReadFile(file)
+
+		if err != nil {
+			continue
+		}
+
+		if rsource.IsGenerated(string(content)) {
+			continue
+		}
+
+		for _, fn := range rsource.Functions(string(content)) {
+			_, exists := e.GetFunction(fn.Name, false)
+
+			if exists {
+				continue
+			}
+
+			tok := token.Item{
+				Class: token.ItemIdent,
+				Value: fn.Name,
+				Line:  fn.Line,
+				Char:  fn.Char,
+				File:  file,
+			}
+
+			e.SetFunction(
+				fn.Name,
+				Function{
+					Token: tok,
+					Value: rFunctionLiteral(tok, fn),
+					// the file stands for the package,
+					// it tells the function is external
+					Package: file,
+					Name:    fn.Name,
+				},
+			)
+		}
+	}
+
+	return nil
+}
+
+func rFunctionLiteral(tok token.Item, fn rsource.Function) *ast.FunctionLiteral {
+	lit := &ast.FunctionLiteral{
+		Token:      tok,
+		Name:       fn.Name,
+		ReturnType: ast.Types{{Name: "any"}},
+	}
+
+	for _, p := range fn.Params {
+		param := &ast.Parameter{
+			Token: token.Item{Class: token.ItemIdent, Value: p.Name, File: tok.File},
+			Name:  p.Name,
+			Type:  ast.Types{{Name: "any"}},
+		}
+
+		if p.HasDefault {
+			param.Operator = "="
+			param.Default = &ast.ExpressionStatement{
+				Token: token.Item{Value: p.Default},
+			}
+		}
+
+		lit.Parameters = append(lit.Parameters, param)
+	}
+
+	return lit
+}
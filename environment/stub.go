@@ -0,0 +1,124 @@
+package environment
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/vapourlang/vapour/ast"
+)
+
+// phrases of the Rd documentation and the types they describe
+var typePhrases = []struct {
+	phrase *regexp.Regexp
+	name   string
+}{
+	{regexp.MustCompile(`(?i)\b(character|strings?)\b`), "char"},
+	{regexp.MustCompile(`(?i)\b(logical|boolean|if true|true or false)\b`), "bool"},
+	{regexp.MustCompile(`(?i)\b(integers?|whole numbers?)\b`), "int"},
+	{regexp.MustCompile(`(?i)\b(numeric|numbers?|double)\b`), "num"},
+}
+
+var (
+	alternative = regexp.MustCompile(`^\s*(,|or|, or)\s*$`)
+	intDefault  = regexp.MustCompile(`^-?[0-9]+L$`)
+	numDefault  = regexp.MustCompile(`^-?[0-9]*\.?[0-9]+(e-?[0-9]+)?$`)
+)
+
+// DescribedType infers the types from the first sentence
+// of a description, e.g. "a character vector", nil if unknown
+func DescribedType(text string) ast.Types {
+	sentence, _, _ := strings.Cut(text, ". ")
+
+	type match struct {
+		start int
+		end   int
+		name  string
+	}
+
+	var matches []match
+	for _, p := range typePhrases {
+		loc := p.phrase.FindStringIndex(sentence)
+
+		if loc == nil {
+			continue
+		}
+
+		matches = append(matches, match{start: loc[0], end: loc[1], name: p.name})
+	}
+
+	// in the order they are described
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+
+	var types ast.Types
+	for i, m := range matches {
+		// other types must be alternatives, e.g. "character or numeric"
+		if i > 0 && !alternative.MatchString(sentence[matches[i-1].end:m.start]) {
+			break
+		}
+
+		types = append(types, &ast.Type{Name: m.name})
+	}
+
+	return types
+}
+
+// DefaultType infers the type from the deparsed default value
+// of a parameter, nil if unknown
+func DefaultType(value string) ast.Types {
+	switch {
+	case value == "TRUE" || value == "FALSE":
+		return ast.Types{{Name: "bool"}}
+	case strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) && len(value) > 1:
+		return ast.Types{{Name: "char"}}
+	case intDefault.MatchString(value):
+		return ast.Types{{Name: "int"}}
+	case numDefault.MatchString(value):
+		return ast.Types{{Name: "num"}}
+	}
+
+	return nil
+}
+
+// Stub writes declarations of the functions of a package
+// that does not ship a types.vp, to be completed by hand
+func Stub(pkg string, fns []*ast.FunctionLiteral) *Code {
+	code := &Code{}
+
+	code.add("# declarations of " + pkg + ", replace `any` with the actual types")
+	code.add("")
+
+	for _, fn := range fns {
+		if !isIdentifier(fn.Name) {
+			code.add("# skipped `" + fn.Name + "`")
+			code.add("")
+			continue
+		}
+
+		code.add(functionDeclaration(fn))
+		code.add("")
+	}
+
+	return code
+}
+
+// isIdentifier checks that the name can be declared,
+// operators such as %>% cannot
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if c == '_' || c == '.' || unicode.IsLetter(c) {
+			continue
+		}
+
+		if i > 0 && unicode.IsDigit(c) {
+			continue
+		}
+
+		return false
+	}
+
+	return name != ""
+}
@@ -0,0 +1,114 @@
+package environment
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/token"
+)
+
+func typeNames(types ast.Types) string {
+	var names []string
+	for _, t := range types {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, " | ")
+}
+
+func TestDescribedType(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"a character vector", "char"},
+		{"logical", "bool"},
+		{"If TRUE, the names are kept", "bool"},
+		{"an integer giving the number of rows", "int"},
+		{"character or numeric", "char | num"},
+		{"a numeric, character or logical vector", "num | char | bool"},
+		// only alternatives are kept
+		{"a character vector with one number per element", "char"},
+		// only the first sentence describes the type
+		{"an object. It is coerced to character", ""},
+		{"an R object", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		got := typeNames(DescribedType(test.text))
+
+		if got != test.expected {
+			t.Fatalf("%q: expected `%v`, got `%v`", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestDefaultType(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"TRUE", "bool"},
+		{"FALSE", "bool"},
+		{`"x"`, "char"},
+		{"1L", "int"},
+		{"-2L", "int"},
+		{"1.5", "num"},
+		{"1", "num"},
+		{"1e-8", "num"},
+		{"NULL", ""},
+		{`paste0("a", "b")`, ""},
+		{`"`, ""},
+	}
+
+	for _, test := range tests {
+		got := typeNames(DefaultType(test.value))
+
+		if got != test.expected {
+			t.Fatalf("%q: expected `%v`, got `%v`", test.value, test.expected, got)
+		}
+	}
+}
+
+func TestStub(t *testing.T) {
+	fns := []*ast.FunctionLiteral{
+		{
+			Name: "greet",
+			Parameters: []*ast.Parameter{
+				{Name: "x", Type: ast.Types{{Name: "char"}}},
+			},
+			ReturnType: ast.Types{{Name: "char"}},
+			Body:       &ast.BlockStatement{},
+		},
+		{
+			Name: "head",
+			Parameters: []*ast.Parameter{
+				{Name: "x", Type: ast.Types{{Name: "any"}}},
+				// deparsed by R
+				{Name: "n", Type: ast.Types{{Name: "int"}}, Default: &ast.ExpressionStatement{Token: token.Item{Value: "6L"}}},
+				{Name: "by", Type: ast.Types{{Name: "num"}}, Default: &ast.ExpressionStatement{Token: token.Item{Value: "-0.5"}}},
+				{Name: "sep", Type: ast.Types{{Name: "char"}}, Default: &ast.ExpressionStatement{Token: token.Item{Value: `", "`}}},
+				{Name: "f", Type: ast.Types{{Name: "any"}}, Default: &ast.ExpressionStatement{Token: token.Item{Value: "identity"}}},
+			},
+			ReturnType: ast.Types{{Name: "any"}},
+			Body:       &ast.BlockStatement{},
+		},
+		{Name: "%>%", Body: &ast.BlockStatement{}},
+	}
+
+	code := Stub("pkg", fns).String()
+
+	expected := []string{
+		"# declarations of pkg",
+		"func greet(x: char): char {}",
+		`func head(x: any, n: int = 6, by: num = -0.5, sep: char = ", ", f: any = NULL): any {}`,
+		"# skipped `%>%`",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(code, e) {
+			t.Fatalf("expected %q in:\n%v", e, code)
+		}
+	}
+}
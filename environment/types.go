@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/vapourlang/vapour/ast"
@@ -23,48 +24,199 @@ func (c *Code) String() string {
 	return strings.Join(c.lines, "\n")
 }
 
+// GenerateTypes writes the declarations other packages load
+// with LoadPackageTypes: types with their decorators, exported
+// functions and methods, sorted so builds are reproducible
 func (e *Environment) GenerateTypes() *Code {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	code := &Code{}
 
-	for typeName, typeObject := range e.types {
-		if IsNativeType(typeName) || IsNativeObject(typeName) {
+	for _, name := range sortedKeys(e.types) {
+		t := e.types[name]
+
+		if t.Package != "" || IsNativeType(name) || IsNativeObject(name) {
 			continue
 		}
 
-		class, classExists := e.GetClass(typeName)
+		e.generateDecorators(code, name)
+		code.add(typeDeclaration(t))
+		code.add("")
+	}
 
-		if classExists {
-			code.add("@class(" + strings.Join(class.Value.Classes, ", ") + ")")
-		}
+	for _, name := range sortedKeys(e.functions) {
+		fn := e.functions[name]
 
-		curlyLeft := "{"
-		if len(typeObject.Attributes) == 0 {
-			curlyLeft = ""
+		if fn.Package != "" || !fn.Exported || !fn.HasSignature() {
+			continue
 		}
 
-		if typeObject.Object != "impliedList" {
-			code.add("type " + typeName + ": " + typeObject.Object + " " + curlyLeft)
-		}
+		code.add(functionDeclaration(fn.Value))
+		code.add("")
+	}
+
+	for _, name := range sortedKeys(e.method) {
+		methods := append(Methods{}, e.method[name]...)
+
+		sort.SliceStable(methods, func(i, j int) bool {
+			return methods[i].Value.Method.Name < methods[j].Value.Method.Name
+		})
+
+		for _, m := range methods {
+			if m.Package != "" || !m.Exported {
+				continue
+			}
+
+			// methods on any are only valid in these decorators
+			if m.Value.Method.Name == "any" && m.Value.Body == nil {
+				code.add("@generic")
+			}
+
+			if m.Value.Method.Name == "any" && m.Value.Body != nil {
+				code.add("@default")
+			}
 
-		if typeObject.Object == "impliedList" {
-			code.add("type " + typeName + ": " + collaseTypes(typeObject.Type) + " " + curlyLeft)
+			code.add(functionDeclaration(m.Value))
+			code.add("")
 		}
+	}
 
-		if len(typeObject.Attributes) == 0 {
-			continue
+	return code
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (e *Environment) generateDecorators(code *Code, name string) {
+	class, ok := e.class[name]
+
+	if ok {
+		code.add("@class(" + strings.Join(class.Value.Classes, ", ") + ")")
+	}
+
+	factor, ok := e.factor[name]
+
+	if ok {
+		code.add("@factor(" + decoratorArguments(factor.Value.Arguments) + ")")
+	}
+
+	matrix, ok := e.matrix[name]
+
+	if ok {
+		code.add("@matrix(" + decoratorArguments(matrix.Value.Arguments) + ")")
+	}
+
+	env, ok := e.env[name]
+
+	if ok {
+		code.add("@environment(" + decoratorArguments(env.Value.Arguments) + ")")
+	}
+}
+
+func decoratorArguments(args []ast.Argument) string {
+	var str []string
+
+	for _, a := range args {
+		str = append(str, a.Value.String())
+	}
+
+	return strings.Join(str, ", ")
+}
+
+func typeDeclaration(t Type) string {
+	switch t.Object {
+	case "vector", "impliedList":
+		return "type " + t.Name + ": " + collaseTypes(t.Type)
+	case "list", "factor", "matrix":
+		return "type " + t.Name + ": " + t.Object + " { " + collaseTypes(t.Type) + " }"
+	}
+
+	var lines []string
+
+	if t.Object == "struct" {
+		lines = append(lines, "\t"+collaseTypes(t.Type))
+	}
+
+	for _, a := range t.Attributes {
+		lines = append(lines, "\t"+a.Name+": "+collaseTypes(a.Type))
+	}
+
+	if len(lines) == 0 {
+		return "type " + t.Name + ": " + t.Object + " {}"
+	}
+
+	return "type " + t.Name + ": " + t.Object + " {\n" + strings.Join(lines, ",\n") + "\n}"
+}
+
+// functionDeclaration writes the signature of the function,
+// generics have no body
+func functionDeclaration(fn *ast.FunctionLiteral) string {
+	var out strings.Builder
+
+	out.WriteString("func ")
+
+	if fn.Method != nil {
+		out.WriteString("(" + fn.MethodVariable + ": " + collaseTypes(ast.Types{fn.Method}) + ") ")
+	}
+
+	var params []string
+	for _, p := range fn.Parameters {
+		param := p.Name + ": " + collaseTypes(p.Type)
+
+		if p.Default != nil {
+			param += " = " + defaultValue(p.Default)
 		}
 
-		for i, a := range typeObject.Attributes {
-			sep := ""
-			if i < len(typeObject.Attributes)-1 {
-				sep = ","
+		params = append(params, param)
+	}
+
+	out.WriteString(fn.Name + "(" + strings.Join(params, ", ") + "): " + collaseTypes(fn.ReturnType))
+
+	if fn.Body != nil {
+		out.WriteString(" {}")
+	}
+
+	return out.String()
+}
+
+// defaultValue writes literal defaults, others cannot always
+// be written back as vapour: these only tell the parameter
+// has a default
+func defaultValue(node *ast.ExpressionStatement) string {
+	// deparsed by R, see r.GetFunctionArguments
+	if node.Expression == nil && intDefault.MatchString(node.Token.Value) {
+		return strings.TrimSuffix(node.Token.Value, "L")
+	}
+
+	// the lexer does not read all of R's escapes
+	if node.Expression == nil && DefaultType(node.Token.Value) != nil && !strings.Contains(node.Token.Value, "\\") {
+		return node.Token.Value
+	}
+
+	switch n := node.Expression.(type) {
+	case *ast.StringLiteral, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean, *ast.Null:
+		return n.String()
+	case *ast.PrefixExpression:
+		// negative numbers
+		switch n.Right.(type) {
+		case *ast.IntegerLiteral, *ast.FloatLiteral:
+			if n.Operator == "-" {
+				return n.Operator + n.Right.String()
 			}
-			code.add("\t" + a.Name + ": " + collaseTypes(a.Type) + sep)
 		}
-		code.add("}")
 	}
 
-	return code
+	return "NULL"
 }
 
 func collaseTypes(types []*ast.Type) string {
@@ -72,6 +224,10 @@ func collaseTypes(types []*ast.Type) string {
 
 	for _, t := range types {
 		typeString := ""
+		if t.Package != "" {
+			typeString += t.Package + "::"
+		}
+
 		if t.List {
 			typeString += "[]"
 		}
@@ -102,32 +258,77 @@ func IsNativeObject(name string) bool {
 	return false
 }
 
-var packagesLoaded []string
+// directories of declaration files named after the package,
+// for packages that do not ship a types.vp
+var registries []string
 
-func isLoaded(library string) bool {
-	for _, p := range packagesLoaded {
-		if p == library {
-			return true
-		}
+// SetRegistries sets the directories searched, in order,
+// before the libraries
+func SetRegistries(dirs []string) {
+	registries = dirs
+}
+
+// DefaultRegistries returns the project's ./types and the
+// user's types directory
+func DefaultRegistries() []string {
+	dirs := []string{"types"}
+
+	dir, err := os.UserConfigDir()
+
+	if err == nil {
+		dirs = append(dirs, path.Join(dir, "vapour", "types"))
 	}
 
-	return false
+	return dirs
+}
+
+// packageTypeFiles lists the files that may declare the package:
+// <registry>/pkg.vp then <library>/pkg/types.vp
+func packageTypeFiles(pkg string) []string {
+	var files []string
+
+	for _, dir := range registries {
+		files = append(files, path.Join(dir, pkg+".vp"))
+	}
+
+	// the first library wins, as in R
+	for _, lib := range library {
+		files = append(files, path.Join(lib, pkg, "types.vp"))
+	}
+
+	return files
 }
 
+// global returns the outermost environment
+func (env *Environment) global() *Environment {
+	for env.outer != nil {
+		env = env.outer
+	}
+
+	return env
+}
+
+// LoadPackageTypes loads the types and function declarations
+// of the package, functions are stored as pkg::fn, the first
+// file found in the registries or libraries is used
 func (env *Environment) LoadPackageTypes(pkg string) {
-	if len(library) == 0 {
+	if pkg == "" {
 		return
 	}
 
-	if isLoaded(pkg) {
+	env = env.global()
+
+	// others wait for the types rather than miss them
+	env.loading.Lock()
+	defer env.loading.Unlock()
+
+	if env.loaded[pkg] {
 		return
 	}
 
-	packagesLoaded = append(packagesLoaded, pkg)
-
-	for _, lib := range library {
-		typeFile := path.Join(lib, pkg, "types.vp")
+	env.loaded[pkg] = true
 
+	for _, typeFile := range packageTypeFiles(pkg) {
 		if _, err := os.Stat(typeFile); errors.Is(err, os.ErrNotExist) {
 			continue
 		}
@@ -154,23 +355,181 @@ func (env *Environment) LoadPackageTypes(pkg string) {
 			continue
 		}
 
-		// range over the Statements
-		// these should all be type declarations
+		// types first, functions refer to them
 		for _, p := range prog.Statements {
 			switch node := p.(type) {
 			case *ast.TypeStatement:
-				env.SetType(
-					Type{
-						Token:      node.Token,
-						Type:       node.Type,
-						Attributes: node.Attributes,
-						Object:     node.Object,
-						Name:       node.Name,
-						Package:    pkg,
-						Used:       true,
-					},
-				)
+				env.loadType(pkg, node)
+			case *ast.ExpressionStatement:
+				env.loadDecorator(pkg, node.Expression)
+			}
+		}
+
+		for _, p := range prog.Statements {
+			es, ok := p.(*ast.ExpressionStatement)
+
+			if !ok {
+				continue
 			}
+
+			env.loadFunction(pkg, es.Expression)
+		}
+
+		return
+	}
+}
+
+func (env *Environment) loadType(pkg string, node *ast.TypeStatement) {
+	if node == nil {
+		return
+	}
+
+	env.SetType(
+		Type{
+			Token:      node.Token,
+			Type:       node.Type,
+			Attributes: node.Attributes,
+			Object:     node.Object,
+			Name:       node.Name,
+			Package:    pkg,
+			Used:       true,
+		},
+	)
+}
+
+// loadDecorator loads decorated types, the decorators
+// are stored as pkg::type
+func (env *Environment) loadDecorator(pkg string, node ast.Expression) {
+	switch n := node.(type) {
+	case *ast.DecoratorClass:
+		env.loadType(pkg, n.Type)
+		if n.Type != nil {
+			env.SetClass(makeTypeKey(pkg, n.Type.Name), Class{Token: n.Token, Value: n})
 		}
+	case *ast.DecoratorFactor:
+		env.loadType(pkg, n.Type)
+		if n.Type != nil {
+			env.SetFactor(makeTypeKey(pkg, n.Type.Name), Factor{Token: n.Token, Value: n})
+		}
+	case *ast.DecoratorMatrix:
+		env.loadType(pkg, n.Type)
+		if n.Type != nil {
+			env.SetMatrix(makeTypeKey(pkg, n.Type.Name), Matrix{Token: n.Token, Value: n})
+		}
+	case *ast.DecoratorEnvironment:
+		env.loadType(pkg, n.Type)
+		if n.Type != nil {
+			env.SetEnv(makeTypeKey(pkg, n.Type.Name), Env{Token: n.Token, Value: n})
+		}
+	}
+}
+
+// loadFunction loads functions, methods and generics
+func (env *Environment) loadFunction(pkg string, node ast.Expression) {
+	switch n := node.(type) {
+	case *ast.DecoratorGeneric:
+		env.loadFunction(pkg, n.Func)
+		return
+	case *ast.DecoratorDefault:
+		env.loadFunction(pkg, n.Func)
+		return
+	}
+
+	fn, ok := node.(*ast.FunctionLiteral)
+
+	if !ok || fn.Name == "" {
+		return
+	}
+
+	env.qualifyTypes(pkg, fn)
+
+	if fn.Method != nil {
+		env.AddMethod(
+			fn.Name,
+			Method{
+				Token:   fn.Token,
+				Package: pkg,
+				Value:   fn,
+				Name:    fn.Name,
+			},
+		)
+		return
+	}
+
+	env.SetFunction(
+		makeTypeKey(pkg, fn.Name),
+		Function{
+			Token:   fn.Token,
+			Value:   fn,
+			Package: pkg,
+			Name:    fn.Name,
+		},
+	)
+}
+
+// qualifyTypes ties the types of the package used
+// in the signature of the function to the package
+func (env *Environment) qualifyTypes(pkg string, fn *ast.FunctionLiteral) {
+	qualify := func(types ast.Types) {
+		for _, t := range types {
+			if t.Package != "" {
+				continue
+			}
+
+			env.mu.RLock()
+			_, ok := env.types[makeTypeKey(pkg, t.Name)]
+			env.mu.RUnlock()
+
+			if ok {
+				t.Package = pkg
+			}
+		}
+	}
+
+	qualify(fn.ReturnType)
+
+	if fn.Method != nil {
+		qualify(ast.Types{fn.Method})
+	}
+
+	for _, p := range fn.Parameters {
+		qualify(p.Type)
+	}
+}
+
+// GetPackageFunction returns the declaration of pkg::name
+func (env *Environment) GetPackageFunction(pkg, name string) (Function, bool) {
+	env.LoadPackageTypes(pkg)
+	env = env.global()
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+	fn, ok := env.functions[makeTypeKey(pkg, name)]
+	return fn, ok
+}
+
+// AttachPackage makes the functions declared by the package
+// callable without namespace, like library(), functions
+// defined in the code take precedence
+func (env *Environment) AttachPackage(pkg string) {
+	env.LoadPackageTypes(pkg)
+
+	env = env.global()
+	prefix := makeTypeKey(pkg, "")
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	for key, fn := range env.functions {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		existing, ok := env.functions[fn.Name]
+
+		if ok && existing.Package == "" {
+			continue
+		}
+
+		env.functions[fn.Name] = fn
 	}
 }
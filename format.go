@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/vapourlang/vapour/format"
+)
+
+// format formats a vapour file or the vapour files of a
+// directory: it prints the result, lists the files that
+// are not formatted (check), or rewrites them (write)
+func (v *vapour) format(path string, check, write bool) {
+	var files []string
+
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && (p == path || filepath.Ext(p) == ".vp") {
+			files = append(files, p)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Fatalf("Failed to read %v: %v", path, err.Error())
+	}
+
+	failed := false
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+
+		if err != nil {
+			log.Fatalf("Failed to read %v: %v", file, err.Error())
+		}
+
+		formatted, d := format.Source(file, content)
+
+		if d != nil {
+			d.Print()
+			failed = true
+			continue
+		}
+
+		if !check && !write {
+			fmt.Print(string(formatted))
+			continue
+		}
+
+		if bytes.Equal(content, formatted) {
+			continue
+		}
+
+		if check {
+			fmt.Println(file)
+			failed = true
+			continue
+		}
+
+		err = os.WriteFile(file, formatted, 0644)
+
+		if err != nil {
+			log.Fatalf("Failed to write %v: %v", file, err.Error())
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
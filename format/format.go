@@ -0,0 +1,382 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/lexer"
+	"github.com/vapourlang/vapour/parser"
+	"github.com/vapourlang/vapour/token"
+)
+
+// Indent is the indentation of a block
+const Indent = "  "
+
+// Source formats vapour code: indentation, spaces between tokens
+// and blank lines are made canonical, comments are kept where
+// they are. The tree drops parentheses and comments within
+// expressions so we print the tokens, the parser only makes
+// sure we do not format invalid code.
+func Source(file string, src []byte) ([]byte, diagnostics.Diagnostics) {
+	code := string(src)
+
+	// the lexer reads the last token up to the new line
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+
+	l := lexer.NewCode(file, code)
+	l.Run()
+
+	if l.HasError() {
+		return nil, l.Errors()
+	}
+
+	p := parser.New(l)
+	p.Run()
+
+	if p.HasError() {
+		return nil, p.Errors()
+	}
+
+	// the lexer appends a new line to the code
+	out := print(code+"\n", l.Items)
+
+	// only whitespace may change
+	formatted := lexer.NewCode(file, out)
+	formatted.Run()
+
+	if d := compare(l.Items, formatted.Items); d != nil {
+		return nil, d
+	}
+
+	return []byte(out), nil
+}
+
+// atom is a token as printed, strings are a single atom
+type atom struct {
+	item  token.Item
+	text  string
+	space bool
+}
+
+type line []atom
+
+type printer struct {
+	out strings.Builder
+	// indentation of the lines opening the brackets
+	open []int
+	// blank lines seen since the last line printed
+	blank int
+	// previous line printed
+	last line
+}
+
+func print(src string, items token.Items) string {
+	pr := &printer{}
+
+	for i, l := range lines(src, items) {
+		pr.line(l, i == 0)
+	}
+
+	return pr.out.String()
+}
+
+// lines splits the tokens on new lines
+func lines(src string, items token.Items) []line {
+	var all []line
+	var current line
+
+	end := 0
+	for i := 0; i < len(items); i++ {
+		it := items[i]
+
+		if it.Class == token.ItemEOF {
+			break
+		}
+
+		start := it.Pos - len(it.Value)
+		gap := src[end:start]
+
+		if it.Class == token.ItemNewLine {
+			end = it.Pos
+
+			// \r\n is a single line break
+			if it.Value == "\r" && i+1 < len(items) && items[i+1].Value == "\n" {
+				continue
+			}
+
+			all = append(all, current)
+			current = nil
+			continue
+		}
+
+		// new lines the lexer skipped, e.g. in struct types
+		for strings.Contains(gap, "\n") {
+			all = append(all, current)
+			current = nil
+			gap = gap[strings.Index(gap, "\n")+1:]
+		}
+
+		a := atom{
+			item: it,
+			// the lexer skips the @ of decorators
+			text:  strings.TrimLeft(gap, " \t\r") + it.Value,
+			space: strings.HasPrefix(gap, " ") || strings.HasPrefix(gap, "\t"),
+		}
+
+		// quotes and content are printed as written
+		if isQuote(it) {
+			j := i + 1
+			for j < len(items) && items[j].Class != it.Class && items[j].Class != token.ItemEOF {
+				j++
+			}
+
+			if j < len(items) && items[j].Class == it.Class {
+				a.text = strings.TrimLeft(gap, " \t\r") + src[start:items[j].Pos]
+				i = j
+			}
+		}
+
+		end = items[i].Pos
+		current = append(current, a)
+	}
+
+	if len(current) > 0 {
+		all = append(all, current)
+	}
+
+	return all
+}
+
+func (pr *printer) line(l line, first bool) {
+	if len(l) == 0 {
+		pr.blank++
+		return
+	}
+
+	// at most one blank line, none at the start
+	// of the file or of a block, nor at its end
+	if pr.blank > 0 && !first && pr.last != nil && !opens(pr.last) && !closer(l[0].item) {
+		pr.out.WriteString("\n")
+	}
+	pr.blank = 0
+
+	// closing brackets are at the level of the line opening them
+	leading := 0
+	indent := -1
+	for leading < len(l) && closer(l[leading].item) {
+		if indent < 0 && len(pr.open) > 0 {
+			indent = pr.open[len(pr.open)-1]
+		}
+
+		pr.close()
+		leading++
+	}
+
+	if indent < 0 {
+		indent = 0
+
+		if len(pr.open) > 0 {
+			indent = pr.open[len(pr.open)-1] + 1
+		}
+
+		if continues(pr.last) {
+			indent++
+		}
+	}
+
+	if indent > 0 {
+		pr.out.WriteString(strings.Repeat(Indent, indent))
+	}
+
+	for i, a := range l {
+		if i > 0 && spaced(l, i) {
+			pr.out.WriteString(" ")
+		}
+
+		pr.out.WriteString(a.text)
+
+		if opener(a.item) {
+			pr.open = append(pr.open, indent)
+		}
+
+		if closer(a.item) && i >= leading {
+			pr.close()
+		}
+	}
+
+	pr.out.WriteString("\n")
+	pr.last = l
+}
+
+func (pr *printer) close() {
+	if len(pr.open) > 0 {
+		pr.open = pr.open[:len(pr.open)-1]
+	}
+}
+
+// spaced decides whether a space precedes the atom at i
+func spaced(l line, i int) bool {
+	prev, next := l[i-1].item, l[i].item
+
+	switch {
+	case next.Class == token.ItemComment:
+		return true
+	case next.Class == token.ItemLeftCurly:
+		return !opener(prev)
+	case next.Class == token.ItemRightCurly:
+		return prev.Class != token.ItemLeftCurly
+	case prev.Class == token.ItemLeftCurly:
+		return true
+	case opener(prev), closer(next):
+		return false
+	case next.Class == token.ItemComma, next.Class == token.ItemColon:
+		return false
+	case prev.Class == token.ItemComma, prev.Class == token.ItemColon:
+		return true
+	case tight(prev), tight(next):
+		return false
+	case prev.Class == token.ItemBang, prev.Class == token.ItemMinus && unary(l, i-1):
+		return false
+	case next.Class == token.ItemElse, prev.Class == token.ItemElse:
+		return true
+	case binary(prev), binary(next):
+		return true
+	case next.Class == token.ItemLeftParen && keyword(prev):
+		return true
+	case next.Class == token.ItemLeftParen, next.Class == token.ItemLeftSquare, next.Class == token.ItemDoubleLeftSquare:
+		return false
+	}
+
+	return l[i].space
+}
+
+// unary checks whether the operator at i has no left operand
+func unary(l line, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	prev := l[i-1].item
+
+	return opener(prev) || binary(prev) || keyword(prev) ||
+		prev.Class == token.ItemComma || prev.Class == token.ItemColon ||
+		prev.Class == token.ItemReturn
+}
+
+// continues checks whether the line ends with an operator
+// the next line completes
+func continues(l line) bool {
+	if len(l) == 0 {
+		return false
+	}
+
+	last := l[len(l)-1].item
+
+	if last.Class == token.ItemComment && len(l) > 1 {
+		last = l[len(l)-2].item
+	}
+
+	return last.Class == token.ItemPipe || last.Class == token.ItemInfix ||
+		last.Class == token.ItemAnd || last.Class == token.ItemOr ||
+		last.Class == token.ItemPlus || last.Class == token.ItemArrow
+}
+
+// opens checks whether the line ends with an opening bracket
+func opens(l line) bool {
+	return len(l) > 0 && opener(l[len(l)-1].item)
+}
+
+func isQuote(it token.Item) bool {
+	return it.Class == token.ItemDoubleQuote || it.Class == token.ItemSingleQuote
+}
+
+func opener(it token.Item) bool {
+	switch it.Class {
+	case token.ItemLeftCurly, token.ItemLeftParen, token.ItemLeftSquare, token.ItemDoubleLeftSquare:
+		return true
+	}
+	return false
+}
+
+func closer(it token.Item) bool {
+	switch it.Class {
+	case token.ItemRightCurly, token.ItemRightParen, token.ItemRightSquare, token.ItemDoubleRightSquare:
+		return true
+	}
+	return false
+}
+
+// tight operators have no spaces around them
+func tight(it token.Item) bool {
+	switch it.Class {
+	case token.ItemDollar, token.ItemNamespace, token.ItemNamespaceInternal,
+		token.ItemRange, token.ItemTypesList, token.ItemPower:
+		return true
+	}
+	return false
+}
+
+func binary(it token.Item) bool {
+	switch it.Class {
+	case token.ItemAssign, token.ItemAssignParent, token.ItemAssignInc, token.ItemAssignDec,
+		token.ItemDoubleEqual, token.ItemNotEqual, token.ItemLessThan, token.ItemGreaterThan,
+		token.ItemLessOrEqual, token.ItemGreaterOrEqual, token.ItemPlus, token.ItemMinus,
+		token.ItemMultiply, token.ItemDivide, token.ItemModulus, token.ItemInfix,
+		token.ItemPipe, token.ItemAnd, token.ItemOr, token.ItemArrow, token.ItemIn:
+		return true
+	}
+	return false
+}
+
+func keyword(it token.Item) bool {
+	switch it.Class {
+	case token.ItemIf, token.ItemFor, token.ItemWhile, token.ItemFunction, token.ItemReturn:
+		return true
+	}
+	return false
+}
+
+// compare checks that formatting only changed whitespace:
+// the tokens are the same, blank lines aside
+func compare(before, after token.Items) diagnostics.Diagnostics {
+	b, a := significant(before), significant(after)
+
+	for i := 0; i < len(b) && i < len(a); i++ {
+		if b[i].Class == a[i].Class && b[i].Value == a[i].Value {
+			continue
+		}
+
+		return diagnostics.Diagnostics{
+			diagnostics.NewError(b[i], "cannot format the code without changing it, please report this"),
+		}
+	}
+
+	if len(b) != len(a) {
+		return diagnostics.Diagnostics{
+			diagnostics.NewError(before[len(before)-1], "cannot format the code without changing it, please report this"),
+		}
+	}
+
+	return nil
+}
+
+// significant drops repeated new lines and those at the start
+func significant(items token.Items) token.Items {
+	var sig token.Items
+
+	for _, it := range items {
+		if it.Class == token.ItemNewLine {
+			it.Value = "\n"
+
+			if len(sig) == 0 || sig[len(sig)-1].Class == token.ItemNewLine {
+				continue
+			}
+		}
+
+		sig = append(sig, it)
+	}
+
+	return sig
+}
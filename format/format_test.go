@@ -0,0 +1,99 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	code := `# add numbers
+
+
+func  add(x:int=1, y: int = -1 ):int{
+# sum
+let z: int = x+y # inline
+    if(z > 0){
+return z
+    }else {
+      return -z
+    }
+}
+
+type config: struct {
+char,
+  x: int
+}
+
+let res: int = lapply(1..10, (i: int): int => {
+return i*2
+}) |>
+sum()`
+
+	expected := `# add numbers
+
+func add(x: int = 1, y: int = -1): int {
+  # sum
+  let z: int = x + y # inline
+  if (z > 0) {
+    return z
+  } else {
+    return -z
+  }
+}
+
+type config: struct {
+  char,
+  x: int
+}
+
+let res: int = lapply(1..10, (i: int): int => {
+  return i * 2
+}) |>
+  sum()
+`
+
+	actual, d := Source("test.vp", []byte(code))
+
+	if d != nil {
+		t.Fatalf("unexpected diagnostics: %v", d)
+	}
+
+	if string(actual) != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, string(actual))
+	}
+
+	again, d := Source("test.vp", actual)
+
+	if d != nil || string(again) != expected {
+		t.Fatalf("formatting is not stable:\n%v", string(again))
+	}
+}
+
+func TestFormatStrings(t *testing.T) {
+	code := `let x: char = "a   \"quoted\"  string"
+let y: char = paste0( 'single' , "double" )
+`
+
+	expected := `let x: char = "a   \"quoted\"  string"
+let y: char = paste0('single', "double")
+`
+
+	actual, d := Source("test.vp", []byte(code))
+
+	if d != nil {
+		t.Fatalf("unexpected diagnostics: %v", d)
+	}
+
+	if string(actual) != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, string(actual))
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	code := `let x: char = "unterminated`
+
+	_, d := Source("test.vp", []byte(code))
+
+	if d == nil {
+		t.Fatal("expected diagnostics on invalid code")
+	}
+}
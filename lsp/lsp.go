@@ -8,11 +8,11 @@ import (
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 	"github.com/tliron/glsp/server"
+	"github.com/vapourlang/vapour/compiler"
 	"github.com/vapourlang/vapour/config"
 	"github.com/vapourlang/vapour/diagnostics"
 	"github.com/vapourlang/vapour/lexer"
-	"github.com/vapourlang/vapour/parser"
-	"github.com/vapourlang/vapour/walker"
+	"github.com/vapourlang/vapour/r"
 )
 
 var src string = "Vapour"
@@ -154,39 +154,16 @@ func (l *LSP) walkFiles(context *glsp.Context, params *walkParams) error {
 		return err
 	}
 
-	// lex
-	le := lexer.New(l.files)
-	le.Run()
-
-	if le.HasError() {
-		diagnostics = addError(diagnostics, le.Errors(), file, l.conf.Lsp.Severity)
-		ds := protocol.PublishDiagnosticsParams{
-			URI:         params.TextDocument,
-			Diagnostics: diagnostics,
-		}
-		context.Notify(protocol.ServerTextDocumentPublishDiagnostics, ds)
-		return nil
-	}
-
-	// parse
-	p := parser.New(le)
-	prog := p.Run()
-
-	if p.HasError() {
-		diagnostics = addError(diagnostics, p.Errors(), file, l.conf.Lsp.Severity)
-		ds := protocol.PublishDiagnosticsParams{
-			URI:         params.TextDocument,
-			Diagnostics: diagnostics,
-		}
-		context.Notify(protocol.ServerTextDocumentPublishDiagnostics, ds)
-		return nil
-	}
+	res := compiler.Check(l.files, compiler.Options{
+		Config: l.conf,
+		// hand-written R files of the package, next to the vapour files
+		RSource: filepath.Join(filepath.Dir(root), "R"),
+	})
 
-	// walk tree
-	w := walker.New()
-	w.Walk(prog)
+	// the server is stopped by the editor, never returns
+	r.SaveCache()
 
-	diagnostics = addError(diagnostics, w.Errors(), file, l.conf.Lsp.Severity)
+	diagnostics = addError(diagnostics, res.Diagnostics, file, l.conf.Lsp.Severity)
 	ds := protocol.PublishDiagnosticsParams{
 		URI:         params.TextDocument,
 		Diagnostics: diagnostics,
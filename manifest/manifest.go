@@ -0,0 +1,222 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vapourlang/vapour/cache"
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/token"
+)
+
+// File is the state of a vapour file at the last build
+type File struct {
+	Hash string `json:"hash"`
+	// hash of what other files may use: top-level
+	// declarations and types, function bodies aside
+	Declarations string `json:"declarations"`
+	// files defining what the file uses
+	Dependencies []string `json:"dependencies,omitempty"`
+	// diagnostics of its statements
+	Diagnostics diagnostics.Diagnostics `json:"diagnostics,omitempty"`
+	// R files generated from it
+	Outputs []string `json:"outputs,omitempty"`
+}
+
+// Manifest records the files of the last build so
+// those that did not change need not be checked again
+type Manifest struct {
+	// version and configuration of the build,
+	// a different key invalidates all files
+	Key   string          `json:"key"`
+	Files map[string]File `json:"files"`
+}
+
+// Path returns where the manifest of the build of indir
+// to outdir is stored, it lives in the cache of vapour
+func Path(indir, outdir string) (string, error) {
+	dir, err := cache.Dir()
+
+	if err != nil {
+		return "", err
+	}
+
+	in, _ := filepath.Abs(indir)
+	out, _ := filepath.Abs(outdir)
+
+	return filepath.Join(dir, "builds", Hash([]byte(in+"\n"+out))+".json"), nil
+}
+
+// Read loads the manifest, it is empty when
+// there is none or it cannot be read
+func Read(path string) *Manifest {
+	m := &Manifest{Files: make(map[string]File)}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return m
+	}
+
+	// a corrupted manifest is ignored and overwritten
+	err = json.Unmarshal(data, m)
+
+	if err != nil || m.Files == nil {
+		return &Manifest{Files: make(map[string]File)}
+	}
+
+	return m
+}
+
+// Write saves the manifest
+func (m *Manifest) Write(path string) error {
+	data, err := json.Marshal(m)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Unchanged returns the files whose content did not change since
+// the build recorded, nor did the declarations of the files they
+// depend on, then or now; files with errors are always checked
+func (m *Manifest) Unchanged(key string, files map[string]File) map[string]bool {
+	unchanged := make(map[string]bool)
+
+	if m.Key != key {
+		return unchanged
+	}
+
+	for path, f := range files {
+		old, ok := m.Files[path]
+
+		if !ok || old.Hash != f.Hash || hasError(old.Diagnostics) {
+			continue
+		}
+
+		if m.changed(files, old.Dependencies) || m.changed(files, f.Dependencies) {
+			continue
+		}
+
+		unchanged[path] = true
+	}
+
+	return unchanged
+}
+
+// changed checks whether the declarations of a file changed
+func (m *Manifest) changed(files map[string]File, paths []string) bool {
+	for _, p := range paths {
+		now, ok := files[p]
+		then, existed := m.Files[p]
+
+		if !ok || !existed || now.Declarations != then.Declarations {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasError checks for diagnostics that fail the build, files
+// with errors or warnings are checked again to report them
+func hasError(ds diagnostics.Diagnostics) bool {
+	for _, d := range ds {
+		if d.Severity != diagnostics.Info && d.Severity != diagnostics.Hint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hash returns the hash of the content
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Declarations returns the hash of the declarations of each file:
+// the tokens outside of braces, those of the bodies of types
+// included, comments and blank lines aside, so editing the body
+// of a function does not change the declarations of its file
+func Declarations(items token.Items) map[string]string {
+	decls := make(map[string]*strings.Builder)
+	last := make(map[string]token.ItemType)
+
+	depth := 0
+	// braces at depth 0 open the body of a type
+	inType := false
+	typeDepth := 0
+	for _, it := range items {
+		if it.Class == token.ItemEOF || it.Class == token.ItemComment {
+			continue
+		}
+
+		b, ok := decls[it.File]
+
+		if !ok {
+			b = &strings.Builder{}
+			decls[it.File] = b
+		}
+
+		switch it.Class {
+		case token.ItemTypesDecl:
+			if depth == 0 {
+				inType = true
+			}
+		case token.ItemNewLine:
+			if depth == 0 {
+				inType = false
+			}
+		case token.ItemLeftCurly:
+			if depth == 0 && inType {
+				typeDepth = 1
+			} else if typeDepth > 0 {
+				typeDepth++
+			}
+			depth++
+		case token.ItemRightCurly:
+			depth--
+			if typeDepth > 0 {
+				typeDepth--
+			}
+			// the closing brace of the body is not part of it
+			if depth == 0 && typeDepth == 0 && !inType {
+				continue
+			}
+		}
+
+		if depth > 0 && typeDepth == 0 {
+			continue
+		}
+
+		// blank lines do not matter
+		if it.Class == token.ItemNewLine && last[it.File] == token.ItemNewLine {
+			continue
+		}
+
+		last[it.File] = it.Class
+		fmt.Fprintf(b, "%d %v\n", it.Class, it.Value)
+	}
+
+	hashes := make(map[string]string)
+	for file, b := range decls {
+		hashes[file] = Hash([]byte(b.String()))
+	}
+
+	return hashes
+}
@@ -0,0 +1,167 @@
+package manifest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/lexer"
+)
+
+func declarations(t *testing.T, code string) string {
+	l := lexer.NewCode("main.vp", code)
+	l.Run()
+
+	if l.HasError() {
+		t.Fatal(l.Errors())
+	}
+
+	return Declarations(l.Items)["main.vp"]
+}
+
+func TestDeclarations(t *testing.T) {
+	base := declarations(t, `type person: object {
+  name: char
+}
+
+# greets
+func greet(p: person): char {
+  return p$name
+}
+`)
+
+	tests := []struct {
+		code    string
+		changed bool
+	}{
+		// body of the function
+		{
+			code: `type person: object {
+  name: char
+}
+
+func greet(p: person): char {
+  let x: char = "hello"
+  return paste(x, p$name)
+}
+`,
+			changed: false,
+		},
+		// signature of the function
+		{
+			code: `type person: object {
+  name: char
+}
+
+func greet(p: person, x: int = 1): char {
+  return p$name
+}
+`,
+			changed: true,
+		},
+		// body of the type
+		{
+			code: `type person: object {
+  name: char,
+  age: int
+}
+
+func greet(p: person): char {
+  return p$name
+}
+`,
+			changed: true,
+		},
+	}
+
+	for i, test := range tests {
+		got := declarations(t, test.code) != base
+
+		if got != test.changed {
+			t.Fatalf("test %v: expected changed to be %v", i, test.changed)
+		}
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	m := &Manifest{
+		Key: "key",
+		Files: map[string]File{
+			"a.vp": {Hash: "a", Declarations: "da"},
+			"b.vp": {Hash: "b", Declarations: "db", Dependencies: []string{"a.vp"}},
+			"c.vp": {
+				Hash:         "c",
+				Declarations: "dc",
+				Diagnostics:  diagnostics.Diagnostics{{Severity: diagnostics.Fatal}},
+			},
+			"d.vp": {
+				Hash:         "d",
+				Declarations: "dd",
+				Diagnostics:  diagnostics.Diagnostics{{Severity: diagnostics.Warn}},
+			},
+			"e.vp": {
+				Hash:         "e",
+				Declarations: "de",
+				Diagnostics:  diagnostics.Diagnostics{{Severity: diagnostics.Hint}},
+			},
+		},
+	}
+
+	files := map[string]File{
+		"a.vp": {Hash: "a2", Declarations: "da"},
+		"b.vp": {Hash: "b", Declarations: "db", Dependencies: []string{"a.vp"}},
+		"c.vp": {Hash: "c", Declarations: "dc"},
+		"d.vp": {Hash: "d", Declarations: "dd"},
+		"e.vp": {Hash: "e", Declarations: "de"},
+	}
+
+	// a changed but not its declarations, c had an error and d a warning
+	unchanged := m.Unchanged("key", files)
+
+	if len(unchanged) != 2 || !unchanged["b.vp"] || !unchanged["e.vp"] {
+		t.Fatalf("expected b.vp and e.vp to be unchanged, got %v", unchanged)
+	}
+
+	// declarations of a changed
+	files["a.vp"] = File{Hash: "a2", Declarations: "da2"}
+	delete(files, "e.vp")
+	unchanged = m.Unchanged("key", files)
+
+	if len(unchanged) != 0 {
+		t.Fatalf("expected no unchanged files, got %v", unchanged)
+	}
+
+	// different version or configuration
+	files["a.vp"] = File{Hash: "a", Declarations: "da"}
+	unchanged = m.Unchanged("other", files)
+
+	if len(unchanged) != 0 {
+		t.Fatalf("expected no unchanged files, got %v", unchanged)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "builds", "build.json")
+
+	// no manifest
+	m := Read(path)
+
+	if len(m.Files) != 0 {
+		t.Fatalf("expected an empty manifest, got %v", m)
+	}
+
+	m.Key = "key"
+	m.Files["a.vp"] = File{Hash: "a", Outputs: []string{"R/a.R"}}
+
+	err := m.Write(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m = Read(path)
+
+	if m.Key != "key" || m.Files["a.vp"].Outputs[0] != "R/a.R" {
+		t.Fatalf("unexpected manifest read: %v", m)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vapourlang/vapour/rsource"
+)
+
+// migrate converts hand-written R files to vapour,
+// each file.R is written to file.vp in the same directory
+func (v *vapour) migrate(path string) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		log.Fatalf("Failed to read %v: %v", path, err.Error())
+	}
+
+	files := []string{path}
+
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.[Rr]"))
+
+		if err != nil {
+			log.Fatalf("Failed to list R files: %v", err.Error())
+		}
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+
+		if err != nil {
+			log.Fatalf("Failed to read %v: %v", file, err.Error())
+		}
+
+		// files we generated have a vapour source already
+		if rsource.IsGenerated(string(content)) {
+			continue
+		}
+
+		out := strings.TrimSuffix(file, filepath.Ext(file)) + ".vp"
+
+		if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "%v already exists, skipping %v\n", out, file)
+			continue
+		}
+
+		code, skipped := rsource.Convert(string(content))
+
+		err = os.WriteFile(out, []byte(code), 0644)
+
+		if err != nil {
+			log.Fatalf("Failed to write %v: %v", out, err.Error())
+		}
+
+		fmt.Printf("converted %v to %v\n", file, out)
+
+		for _, s := range skipped {
+			fmt.Fprintf(os.Stderr, "%v:%v:%v left as is, %v\n", file, s.Line+1, s.Char+1, s.Reason)
+		}
+	}
+}
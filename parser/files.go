@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/lexer"
+	"github.com/vapourlang/vapour/token"
+)
+
+// File is a vapour file lexed and parsed on its own
+type File struct {
+	Path    string
+	Items   token.Items
+	Program *ast.Program
+	// lexing or parsing errors, the file is
+	// not parsed if it could not be lexed
+	Errors diagnostics.Diagnostics
+}
+
+type Files []*File
+
+// ParseFiles lexes and parses the files concurrently, each into
+// its own program, they are returned in the order given
+func ParseFiles(files lexer.Files) Files {
+	parsed := make(Files, len(files))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i, f := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, f lexer.File) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			parsed[i] = parseFile(f)
+		}(i, f)
+	}
+
+	wg.Wait()
+
+	return parsed
+}
+
+func parseFile(f lexer.File) *File {
+	file := &File{Path: f.Path}
+
+	l := lexer.New(lexer.Files{f})
+	l.Run()
+	file.Items = l.Items
+
+	if l.HasError() {
+		file.Errors = l.Errors()
+		return file
+	}
+
+	p := New(l)
+	file.Program = p.Run()
+
+	if p.HasError() {
+		file.Errors = p.Errors()
+	}
+
+	return file
+}
+
+// HasError reports whether a file could not be lexed or parsed
+func (fs Files) HasError() bool {
+	for _, f := range fs {
+		if len(f.Errors) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Errors returns the errors of the files, in their order
+func (fs Files) Errors() diagnostics.Diagnostics {
+	var errs diagnostics.Diagnostics
+
+	for _, f := range fs {
+		errs = append(errs, f.Errors...)
+	}
+
+	return errs
+}
+
+// Merge returns the program of all the files and their tokens,
+// as a single lexer and parser would, the declarations of
+// all files are then checked together
+func (fs Files) Merge() (*ast.Program, token.Items) {
+	prog := &ast.Program{Statements: []ast.Statement{}}
+	var items token.Items
+
+	for i, f := range fs {
+		if f.Program != nil {
+			prog.Statements = append(prog.Statements, f.Program.Statements...)
+		}
+
+		tokens := f.Items
+
+		// only the last EOF is kept
+		if i < len(fs)-1 && len(tokens) > 0 && tokens[len(tokens)-1].Class == token.ItemEOF {
+			tokens = tokens[:len(tokens)-1]
+		}
+
+		items = append(items, tokens...)
+	}
+
+	return prog, items
+}
@@ -89,6 +89,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.ItemNAReal, p.parseNaReal)
 	p.registerPrefix(token.ItemNAInteger, p.parseNaInteger)
 	p.registerPrefix(token.ItemInf, p.parseInf)
+	p.registerPrefix(token.ItemBreak, p.parseBreak)
+	p.registerPrefix(token.ItemNext, p.parseNext)
 	p.registerPrefix(token.ItemNULL, p.parseNull)
 	p.registerPrefix(token.ItemThreeDot, p.parseElipsis)
 	p.registerPrefix(token.ItemString, p.parseNaString)
@@ -413,6 +415,22 @@ func (p *Parser) parseInf() ast.Expression {
 	}
 }
 
+func (p *Parser) parseBreak() ast.Expression {
+	return &ast.Keyword{
+		Token: p.curToken,
+		Value: "break",
+		Type:  &ast.Type{Name: "null"},
+	}
+}
+
+func (p *Parser) parseNext() ast.Expression {
+	return &ast.Keyword{
+		Token: p.curToken,
+		Value: "next",
+		Type:  &ast.Type{Name: "null"},
+	}
+}
+
 func (p *Parser) parseTypeDeclarations() ast.Statement {
 	p.nextToken()
 	p.nextToken()
@@ -1351,21 +1369,25 @@ func (p *Parser) parseTypes() ast.Types {
 		}
 
 		pkg := ""
+		list := false
 		if p.curTokenIs(token.ItemTypesPkg) {
 			pkg = p.curToken.Value
 			// skip namespace
 			p.nextToken()
 			p.nextToken()
-		}
 
-		// is list
-		p.previousToken(1)
-		tok := p.curToken
-		p.nextToken()
+			// list of the package's type, pkg::[]name
+			if p.curTokenIs(token.ItemTypesList) {
+				list = true
+				p.nextToken()
+			}
+		} else {
+			// is list
+			p.previousToken(1)
+			tok := p.curToken
+			p.nextToken()
 
-		list := false
-		if tok.Class == token.ItemTypesList {
-			list = true
+			list = tok.Class == token.ItemTypesList
 		}
 
 		t = append(t, &ast.Type{Name: p.curToken.Value, List: list, Package: pkg})
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/vapourlang/vapour/ast"
 	"github.com/vapourlang/vapour/lexer"
 )
 
@@ -736,6 +737,24 @@ func TestTypePackage(t *testing.T) {
 	}
 
 	fmt.Println(prog.String())
+
+	// list of a type of the package
+	l = lexer.NewTest(`let x: tibble::[]tbl | []int`)
+
+	l.Run()
+	p = New(l)
+
+	prog = p.Run()
+
+	if len(p.errors) > 0 {
+		t.Fatal(p.errors)
+	}
+
+	types := prog.Statements[0].(*ast.LetStatement).Type
+
+	if len(types) != 2 || types[0].Package != "tibble" || types[0].Name != "tbl" || !types[0].List || !types[1].List {
+		t.Fatalf("unexpected types: %v", types)
+	}
 }
 
 func TestInline(t *testing.T) {
@@ -789,3 +808,49 @@ let x: int = (1, 20, 23) `
 
 	fmt.Println(prog.String())
 }
+
+func TestParseFiles(t *testing.T) {
+	files := lexer.Files{
+		{Path: "a.vp", Content: []byte(`let x: int = 1`)},
+		{Path: "b.vp", Content: []byte(`func add(y: int = 1): int {
+  return x + y
+}`)},
+	}
+
+	parsed := ParseFiles(files)
+
+	if parsed.HasError() {
+		t.Fatal(parsed.Errors())
+	}
+
+	// as a single lexer and parser would
+	l := lexer.New(files)
+	l.Run()
+	expected := New(l).Run()
+
+	prog, items := parsed.Merge()
+
+	if prog.String() != expected.String() {
+		t.Fatalf("expected\n%v\ngot\n%v", expected.String(), prog.String())
+	}
+
+	if len(items) != len(l.Items) {
+		t.Fatalf("expected %v tokens, got %v", len(l.Items), len(items))
+	}
+
+	for i, it := range items {
+		if it != l.Items[i] {
+			t.Fatalf("token %v: expected %v, got %v", i, l.Items[i], it)
+		}
+	}
+
+	// errors are those of the file
+	parsed = ParseFiles(lexer.Files{
+		{Path: "a.vp", Content: []byte(`let x: int = 1`)},
+		{Path: "b.vp", Content: []byte(`let y: int = "a`)},
+	})
+
+	if !parsed.HasError() || len(parsed[0].Errors) != 0 || len(parsed[1].Errors) == 0 {
+		t.Fatalf("expected errors in b.vp only, got %v", parsed.Errors())
+	}
+}
@@ -5,53 +5,104 @@ import (
 	"fmt"
 
 	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/cache"
+	"github.com/vapourlang/vapour/token"
 )
 
 type arg struct {
-	Name  string `json:"name"`
-	Value string `json:"value"`
+	Name string `json:"name"`
+	// deparsed default value, if any
+	Value      string `json:"value"`
+	HasDefault bool   `json:"hasDefault"`
 }
 
 type args []arg
 
-func GetFunctionArguments(pkg, operator, function string) *ast.FunctionLiteral {
-	obj := &ast.FunctionLiteral{}
+// GetFunctionArguments builds the signature of a package
+// function from its formals, we do not know the types
+// of the parameters so these are all `any`
+func GetFunctionArguments(pkg, operator, function string) (*ast.FunctionLiteral, error) {
 	function = pkg + operator + function
+	key := FUNCTION + function
 
-	output, err := Callr(
-		fmt.Sprintf(`args <- as.list(args(%v))
-    if(length(args) == 0){
-		  cat("[]")
-			return()
-		}
+	c, ok := cache.Get(key)
 
-    N <- length(args) - 1L
-		args <- args[1:N]
+	if ok {
+		return c.(*ast.FunctionLiteral), nil
+	}
 
-    json_string <- c()
-    for(i in seq_along(args)) {
-			arg_string <- paste0(
-				'{"name": "', names(args)[i], '", "value": "', deparse(args[i]), '"}'
-			)
-			json_string <- c(json_string, arg_string)
+	var args args
+
+	if !cache.Load(key, &args) {
+		fetched, err := fetchArguments(function)
+
+		if err != nil {
+			return nil, err
 		}
 
-		json_string <- paste0(json_string, collapse = ",")
-		cat(paste0("[", json_string, "]"))`,
+		args = fetched
+		cache.Store(key, pkg, args)
+	}
+
+	obj := newFunctionLiteral(function, args)
+
+	cache.Set(key, obj)
+
+	return obj, nil
+}
+
+// fetchArguments queries R for the formals of the function
+func fetchArguments(function string) (args, error) {
+	output, err := Query(
+		fmt.Sprintf(`fmls <- formals(args(%v))
+		json <- vapply(seq_along(fmls), function(i) {
+			has_default <- !identical(fmls[[i]], quote(expr = ))
+			value <- ""
+			if(has_default) {
+				value <- paste0(deparse(fmls[[i]]), collapse = "")
+			}
+			paste0(
+				'{"name":', encodeString(names(fmls)[i], quote = '"'),
+				',"value":', encodeString(value, quote = '"'),
+				',"hasDefault":', tolower(has_default), '}'
+			)
+		}, character(1))
+		cat(paste0("[", paste0(json, collapse = ","), "]"))`,
 			function,
 		),
 	)
 
 	if err != nil {
-		return obj
+		return nil, err
 	}
 
 	var args args
 
 	err = json.Unmarshal(output, &args)
 
-	if err != nil {
-		return obj
+	return args, err
+}
+
+func newFunctionLiteral(function string, args args) *ast.FunctionLiteral {
+	obj := &ast.FunctionLiteral{
+		Name:       function,
+		ReturnType: ast.Types{{Name: "any"}},
+	}
+
+	for _, a := range args {
+		param := &ast.Parameter{
+			Token: token.Item{Class: token.ItemIdent, Value: a.Name},
+			Name:  a.Name,
+			Type:  ast.Types{{Name: "any"}},
+		}
+
+		if a.HasDefault {
+			param.Default = &ast.ExpressionStatement{
+				Token: token.Item{Value: a.Value},
+			}
+		}
+
+		obj.Parameters = append(obj.Parameters, param)
 	}
 
 	return obj
@@ -17,7 +17,13 @@ const (
 	FUNCTION     = "FUNCTION"
 )
 
+// ListBaseFunctions lists the functions of the packages attached
+// by default, in offline mode these come from the embedded snapshot
 func ListBaseFunctions() ([]Package, error) {
+	if Offline() {
+		return snapshotFunctions()
+	}
+
 	c, ok := cache.Get(BASEPACKAGES)
 
 	if ok {
@@ -26,7 +32,12 @@ func ListBaseFunctions() ([]Package, error) {
 
 	var packages []Package
 
-	output, err := Callr(
+	if cache.Load(BASEPACKAGES, &packages) {
+		cache.Set(BASEPACKAGES, packages)
+		return packages, nil
+	}
+
+	output, err := Query(
 		`base_packages = getOption('defaultPackages')
 		base_packages <- c(base_packages, "base")
 		pkgs <- lapply(base_packages, function (pkg){
@@ -43,16 +54,19 @@ func ListBaseFunctions() ([]Package, error) {
 	)
 
 	if err != nil {
+		packages, _ = snapshotFunctions()
 		return packages, err
 	}
 
 	err = json.Unmarshal(output, &packages)
 
 	if err != nil {
+		packages, _ = snapshotFunctions()
 		return packages, err
 	}
 
 	cache.Set(BASEPACKAGES, packages)
+	cache.Store(BASEPACKAGES, "base", packages)
 
 	return packages, err
 }
@@ -65,7 +79,12 @@ func PackageHasFunction(pkg, operator, fn string) (bool, error) {
 		return c.(bool), nil
 	}
 
-	output, err := Callr(
+	if cache.Load(key, &ok) {
+		cache.Set(key, ok)
+		return ok, nil
+	}
+
+	output, err := Query(
 		fmt.Sprintf("res <- tryCatch(%v%v%v);cat(inherits(res, 'error'))", pkg, operator, fn),
 	)
 
@@ -76,6 +95,7 @@ func PackageHasFunction(pkg, operator, fn string) (bool, error) {
 	ok = string(output) == "FALSE"
 
 	cache.Set(key, ok)
+	cache.Store(key, pkg, ok)
 
 	return ok, err
 }
@@ -88,7 +108,12 @@ func PackageIsInstalled(pkg string) (bool, error) {
 		return c.(bool), nil
 	}
 
-	output, err := Callr(
+	if cache.Load(key, &ok) {
+		cache.Set(key, ok)
+		return ok, nil
+	}
+
+	output, err := Query(
 		fmt.Sprintf("res <- requireNamespace('%v');cat(res)", pkg),
 	)
 
@@ -99,6 +124,26 @@ func PackageIsInstalled(pkg string) (bool, error) {
 	ok = string(output) == "TRUE"
 
 	cache.Set(key, ok)
+	cache.Store(key, pkg, ok)
 
 	return ok, err
 }
+
+// PackageExports lists the functions exported by the package
+func PackageExports(pkg string) ([]string, error) {
+	var exports []string
+
+	output, err := Query(
+		fmt.Sprintf(`ns <- asNamespace('%v')
+		fns <- Filter(function(x) is.function(get(x, envir = ns)), getNamespaceExports(ns))
+		cat(paste0("[", paste0(encodeString(sort(fns), quote = '"'), collapse = ","), "]"))`, pkg),
+	)
+
+	if err != nil {
+		return exports, err
+	}
+
+	err = json.Unmarshal(output, &exports)
+
+	return exports, err
+}
@@ -0,0 +1,175 @@
+package r
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vapourlang/vapour/cache"
+)
+
+const LIBPATH = "LIBPATH"
+
+// toolchain identifies the R installation the cache belongs to,
+// version and libraries are only fetched from R when it changes
+type toolchain struct {
+	Path    string   `json:"path"`
+	Size    int64    `json:"size"`
+	ModTime int64    `json:"modTime"`
+	Env     []string `json:"env"`
+	Version string   `json:"version"`
+	Libs    []string `json:"libs"`
+}
+
+// environment variables that change the library paths
+var libEnv = []string{"R_LIBS", "R_LIBS_USER", "R_LIBS_SITE"}
+
+// OpenCache loads the results of previous R queries
+// for the R toolchain in use
+func OpenCache() error {
+	current, err := currentToolchain()
+
+	if err != nil {
+		return err
+	}
+
+	dir, err := cache.Dir()
+
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(dir, "toolchain.json")
+	previous := readToolchain(file)
+
+	if !previous.matches(current) {
+		err = current.query()
+
+		if err != nil {
+			return err
+		}
+
+		previous = current
+
+		err = os.MkdirAll(dir, 0755)
+
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(current)
+
+		if err == nil {
+			os.WriteFile(file, data, 0644)
+		}
+	}
+
+	cache.Set(LIBPATH, previous.Libs)
+
+	return cache.Open(previous.Version, previous.Libs)
+}
+
+// SaveCache writes the results of the R queries made since
+// the cache was opened or last saved
+func SaveCache() {
+	cache.Flush()
+}
+
+// readToolchain reads the toolchain of the previous run,
+// it is empty if there is none
+func readToolchain(file string) toolchain {
+	var t toolchain
+	data, err := os.ReadFile(file)
+
+	if err == nil {
+		json.Unmarshal(data, &t)
+	}
+
+	return t
+}
+
+// cachedVersion returns the version of R recorded by the
+// previous run if the executable is unchanged, empty otherwise
+func cachedVersion(path string) string {
+	dir, err := cache.Dir()
+
+	if err != nil {
+		return ""
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return ""
+	}
+
+	t := readToolchain(filepath.Join(dir, "toolchain.json"))
+
+	if t.Path != path || t.Size != info.Size() || t.ModTime != info.ModTime().UnixNano() {
+		return ""
+	}
+
+	return t.Version
+}
+
+func currentToolchain() (toolchain, error) {
+	var t toolchain
+
+	r, err := current()
+
+	if err != nil {
+		return t, err
+	}
+
+	info, err := os.Stat(r.Path)
+
+	if err != nil {
+		return t, err
+	}
+
+	t.Path = r.Path
+	t.Size = info.Size()
+	t.ModTime = info.ModTime().UnixNano()
+
+	for _, e := range libEnv {
+		t.Env = append(t.Env, e+"="+os.Getenv(e))
+	}
+
+	// configured libraries are passed to R with R_LIBS
+	t.Env = append(t.Env, "libs="+strings.Join(r.Libs, string(os.PathListSeparator)))
+
+	return t, nil
+}
+
+func (t toolchain) matches(other toolchain) bool {
+	if t.Path != other.Path || t.Size != other.Size || t.ModTime != other.ModTime {
+		return false
+	}
+
+	if len(t.Env) != len(other.Env) {
+		return false
+	}
+
+	for i := range t.Env {
+		if t.Env[i] != other.Env[i] {
+			return false
+		}
+	}
+
+	return t.Version != ""
+}
+
+// query fetches the version and library paths from R
+func (t *toolchain) query() error {
+	output, err := Query(
+		`libs <- paste0(encodeString(.libPaths(), quote = '"'), collapse = ",")
+		cat(paste0('{"version":"', as.character(getRversion()), '","libs":[', libs, ']}'))`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(output, t)
+}
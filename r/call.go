@@ -1,14 +1,11 @@
 package r
 
-import "os/exec"
+func Callr(code string) ([]byte, error) {
+	cmd, err := Command(code)
 
-func Callr(cmd string) ([]byte, error) {
-	out, err := exec.Command(
-		"R",
-		"-s",
-		"-e",
-		cmd,
-	).Output()
+	if err != nil {
+		return nil, err
+	}
 
-	return out, err
+	return cmd.Output()
 }
@@ -0,0 +1,57 @@
+package r
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Doc is the documentation of a help topic
+type Doc struct {
+	Aliases []string `json:"aliases"`
+	// description of each parameter
+	Params map[string]string `json:"params"`
+	// description of the returned value
+	Value string `json:"value"`
+}
+
+// PackageDocs reads the Rd files of an installed package
+func PackageDocs(pkg string) ([]Doc, error) {
+	var docs []Doc
+
+	output, err := Query(
+		fmt.Sprintf(`text <- function(x) trimws(gsub("\\s+", " ", paste0(unlist(x), collapse = "")))
+		str <- function(x) encodeString(x, quote = '"')
+		tag <- function(x) {
+			t <- attr(x, "Rd_tag")
+			if(is.null(t)) "" else t
+		}
+		json <- vapply(tools::Rd_db('%v'), function(rd) {
+			tags <- vapply(rd, tag, character(1))
+			aliases <- vapply(rd[tags == "\\alias"], text, character(1))
+			params <- character(0)
+			for(args in rd[tags == "\\arguments"]) {
+				for(item in args[vapply(args, tag, character(1)) == "\\item"]) {
+					if(length(item) < 2) next
+					for(name in trimws(strsplit(text(item[[1]]), ",")[[1]])) {
+						params <- c(params, paste0(str(name), ":", str(text(item[[2]]))))
+					}
+				}
+			}
+			value <- paste0(vapply(rd[tags == "\\value"], text, character(1)), collapse = " ")
+			paste0(
+				'{"aliases":[', paste0(str(aliases), collapse = ","), ']',
+				',"params":{', paste0(params, collapse = ","), '}',
+				',"value":', str(value), '}'
+			)
+		}, character(1))
+		cat(paste0("[", paste0(json, collapse = ","), "]"))`, pkg),
+	)
+
+	if err != nil {
+		return docs, err
+	}
+
+	err = json.Unmarshal(output, &docs)
+
+	return docs, err
+}
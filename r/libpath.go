@@ -2,16 +2,24 @@ package r
 
 import (
 	"encoding/json"
+
+	"github.com/vapourlang/vapour/cache"
 )
 
 func LibPath() []string {
+	c, ok := cache.Get(LIBPATH)
+
+	if ok {
+		return c.([]string)
+	}
+
 	var paths []string
-	output, err := Callr(`paths <- paste0(.libPaths(), collapse = "\",\"")
+	output, err := Query(`paths <- paste0(.libPaths(), collapse = "\",\"")
 	paths <- paste0("[\"", paths, "\"]")
 	cat(paths)`)
 
 	if err != nil {
-		return paths
+		return configuredLibs()
 	}
 
 	err = json.Unmarshal(output, &paths)
@@ -20,5 +28,18 @@ func LibPath() []string {
 		return paths
 	}
 
+	cache.Set(LIBPATH, paths)
+
 	return paths
 }
+
+// configuredLibs returns the libraries we know of without R
+func configuredLibs() []string {
+	t, err := current()
+
+	if err != nil {
+		return nil
+	}
+
+	return t.libs()
+}
@@ -0,0 +1,52 @@
+package r
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrOffline is returned by queries when R is not available
+var ErrOffline = errors.New("R is not available")
+
+// base function names of the packages attached by default,
+// used in place of ListBaseFunctions when R is not available,
+// it can be refreshed with the output of ListBaseFunctions
+//
+//go:embed snapshot/packages.json
+var snapshot []byte
+
+var (
+	offline     bool
+	offlineSet  bool
+	offlineOnce sync.Once
+)
+
+// SetOffline forces the offline mode on or off,
+// by default it is on when no R toolchain is found
+func SetOffline(on bool) {
+	offline = on
+	offlineSet = true
+}
+
+// Offline reports whether R should not be queried
+func Offline() bool {
+	if offlineSet {
+		return offline
+	}
+
+	offlineOnce.Do(func() {
+		_, err := current()
+		offline = err != nil
+	})
+
+	return offline
+}
+
+// snapshotFunctions returns the base functions embedded in the binary
+func snapshotFunctions() ([]Package, error) {
+	var packages []Package
+	err := json.Unmarshal(snapshot, &packages)
+	return packages, err
+}
@@ -0,0 +1,200 @@
+package r
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MinVersion is the oldest R release supported
+const MinVersion = "4.0.0"
+
+// environment variable pointing to the R executable,
+// it takes precedence over the configuration
+const ENVVAR = "VAPOUR_R"
+
+// Toolchain is the R installation used to query and run code
+type Toolchain struct {
+	Path string
+	// Rscript takes different flags than R
+	Rscript bool
+	// library paths placed before those of R
+	Libs    []string
+	Version string
+}
+
+var (
+	selected    *Toolchain
+	resolveErr  error
+	resolveOnce sync.Once
+)
+
+// Resolve finds the R executable from, in order, the VAPOUR_R
+// environment variable, the configured path, and R or Rscript
+// on the PATH
+func Resolve(path string, libs []string) (*Toolchain, error) {
+	if env := os.Getenv(ENVVAR); env != "" {
+		return newToolchain(env, libs, ENVVAR)
+	}
+
+	if path != "" {
+		return newToolchain(path, libs, "the `r.path` configuration")
+	}
+
+	for _, name := range []string{"R", "Rscript"} {
+		p, err := exec.LookPath(name)
+
+		if err == nil {
+			return newToolchain(p, libs, "the PATH")
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"R not found: install R, set %v or `r.path` in ~/.vapour to the R executable, or pass -offline",
+		ENVVAR,
+	)
+}
+
+func newToolchain(path string, libs []string, from string) (*Toolchain, error) {
+	p, err := exec.LookPath(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot run `%v` set by %v: %v", path, from, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+
+	return &Toolchain{
+		Path:    p,
+		Rscript: strings.EqualFold(name, "Rscript"),
+		Libs:    libs,
+	}, nil
+}
+
+// Setup resolves the toolchain, checks its version and uses
+// it for all subsequent calls to R, turning off the offline mode
+func Setup(path string, libs []string) error {
+	t, err := Resolve(path, libs)
+
+	if err != nil {
+		return err
+	}
+
+	// R is only started when the executable changed
+	t.Version = cachedVersion(t.Path)
+
+	if t.Version == "" {
+		output, err := t.command(`cat(as.character(getRversion()))`).Output()
+
+		if err != nil {
+			return fmt.Errorf("failed to run R at %v: %v", t.Path, err)
+		}
+
+		t.Version = strings.TrimSpace(string(output))
+	}
+
+	if compareVersions(t.Version, MinVersion) < 0 {
+		return fmt.Errorf(
+			"R %v at %v is too old, vapour requires R >= %v: set %v to a newer R",
+			t.Version,
+			t.Path,
+			MinVersion,
+			ENVVAR,
+		)
+	}
+
+	selected = t
+	SetOffline(false)
+
+	return nil
+}
+
+// current returns the toolchain selected by Setup,
+// or the one found on the PATH if Setup was not called
+func current() (*Toolchain, error) {
+	if selected != nil {
+		return selected, nil
+	}
+
+	resolveOnce.Do(func() {
+		selected, resolveErr = Resolve("", nil)
+	})
+
+	return selected, resolveErr
+}
+
+// Command returns the command evaluating the code with the toolchain
+func Command(code string) (*exec.Cmd, error) {
+	t, err := current()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.command(code), nil
+}
+
+func (t *Toolchain) command(code string) *exec.Cmd {
+	var cmd *exec.Cmd
+
+	if t.Rscript {
+		cmd = exec.Command(t.Path, "-e", code)
+	} else {
+		cmd = exec.Command(t.Path, "-s", "--no-save", "-e", code)
+	}
+
+	if len(t.Libs) > 0 {
+		cmd.Env = append(os.Environ(), "R_LIBS="+strings.Join(t.libs(), string(os.PathListSeparator)))
+	}
+
+	return cmd
+}
+
+// libs returns the configured libraries followed by those of R_LIBS
+func (t *Toolchain) libs() []string {
+	libs := append([]string{}, t.Libs...)
+
+	for _, l := range filepath.SplitList(os.Getenv("R_LIBS")) {
+		if l != "" {
+			libs = append(libs, l)
+		}
+	}
+
+	return libs
+}
+
+// compareVersions compares R versions such as 4.3.1 or 4.4-0
+func compareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(r rune) bool {
+			return r == '.' || r == '-'
+		})
+	}
+
+	as, bs := split(a), split(b)
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,233 @@
+package r
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// number of R sessions kept alive
+const workers = 2
+
+// time given to R to answer a query
+var queryTimeout = 60 * time.Second
+
+// the worker failed: it crashed or timed out
+var errWorker = errors.New("R worker failed")
+
+// script run by the R sessions: it reads one request per line
+// and answers with one line, the code and output are hex encoded
+// so we do not have to parse JSON in base R
+const workerScript = `con <- file("stdin", open = "r")
+hex_decode <- function(x) {
+  if(nchar(x) == 0) return("")
+  rawToChar(as.raw(strtoi(substring(x, seq(1, nchar(x), 2), seq(2, nchar(x), 2)), 16L)))
+}
+hex_encode <- function(x) paste0(as.character(charToRaw(x)), collapse = "")
+while(length(line <- readLines(con, n = 1L)) > 0) {
+  id <- sub('.*"id":([0-9]+).*', "\\1", line)
+  code <- hex_decode(sub('.*"code":"([0-9a-f]*)".*', "\\1", line))
+  ok <- TRUE
+  output <- tryCatch({
+    env <- new.env(parent = globalenv())
+    paste0(capture.output({
+      for(e in parse(text = code)) {
+        res <- withVisible(eval(e, env))
+        if(res$visible) print(res$value)
+      }
+    }), collapse = "\n")
+  }, error = function(e) {
+    ok <<- FALSE
+    conditionMessage(e)
+  })
+  cat(sprintf('{"id":%s,"ok":%s,"output":"%s"}\n', id, tolower(ok), hex_encode(output)))
+  flush(stdout())
+}`
+
+type worker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	id     int
+}
+
+type response struct {
+	ID     int    `json:"id"`
+	Ok     bool   `json:"ok"`
+	Output string `json:"output"`
+	err    error
+}
+
+type pool struct {
+	// nil slots have no session started
+	slots chan *worker
+}
+
+var sessions = newPool(workers)
+
+func newPool(size int) *pool {
+	p := &pool{slots: make(chan *worker, size)}
+
+	for i := 0; i < size; i++ {
+		p.slots <- nil
+	}
+
+	return p
+}
+
+func (p *pool) get() (*worker, error) {
+	w := <-p.slots
+
+	if w != nil {
+		return w, nil
+	}
+
+	w, err := startWorker()
+
+	if err != nil {
+		p.slots <- nil
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (p *pool) put(w *worker) {
+	p.slots <- w
+}
+
+// Query runs the code in a long-lived R session and returns
+// what it printed, falling back to a new R process (Callr)
+// when no session is available or the session fails,
+// it returns ErrOffline in offline mode
+func Query(code string) ([]byte, error) {
+	if Offline() {
+		return nil, ErrOffline
+	}
+
+	w, err := sessions.get()
+
+	if err != nil {
+		return Callr(code)
+	}
+
+	output, err := w.query(code, queryTimeout)
+
+	if errors.Is(err, errWorker) {
+		// the slot is restarted on next use
+		w.kill()
+		sessions.put(nil)
+		return Callr(code)
+	}
+
+	sessions.put(w)
+
+	return output, err
+}
+
+func startWorker() (*worker, error) {
+	cmd, err := Command(workerScript)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &worker{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+func (w *worker) query(code string, timeout time.Duration) ([]byte, error) {
+	w.id++
+	id := w.id
+
+	_, err := fmt.Fprintf(w.stdin, "{\"id\":%d,\"code\":\"%s\"}\n", id, hex.EncodeToString([]byte(code)))
+
+	if err != nil {
+		return nil, errWorker
+	}
+
+	done := make(chan response, 1)
+	go func() {
+		done <- w.read(id)
+	}()
+
+	var res response
+	select {
+	case res = <-done:
+	case <-time.After(timeout):
+		return nil, errWorker
+	}
+
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	output, err := hex.DecodeString(res.Output)
+
+	if err != nil {
+		return nil, errWorker
+	}
+
+	if !res.Ok {
+		return nil, fmt.Errorf("R error: %v", string(output))
+	}
+
+	return output, nil
+}
+
+// read skips lines until the response to the request,
+// code run by the session may print to the console
+func (w *worker) read(id int) response {
+	for {
+		line, err := w.stdout.ReadString('\n')
+
+		if err != nil {
+			return response{err: errWorker}
+		}
+
+		var res response
+		err = json.Unmarshal([]byte(line), &res)
+
+		if err != nil || res.ID != id {
+			continue
+		}
+
+		return res
+	}
+}
+
+func (w *worker) kill() {
+	w.stdin.Close()
+
+	if w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+
+	w.cmd.Wait()
+}
@@ -9,6 +9,9 @@ import (
 )
 
 func (v *vapour) readDir() error {
+	// files are read again on every run of -watch
+	v.files = nil
+
 	err := filepath.WalkDir(*v.root, v.walk)
 
 	if err != nil {
@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os/exec"
 
 	"github.com/vapourlang/vapour/lexer"
 	"github.com/vapourlang/vapour/parser"
+	"github.com/vapourlang/vapour/r"
 	"github.com/vapourlang/vapour/transpiler"
 )
 
@@ -19,10 +19,7 @@ func (v *vapour) replIntro() string {
 }
 
 func (v *vapour) repl(in io.Reader, out io.Writer, er io.Writer) {
-	cmd := exec.Command(
-		"R",
-		"-s",
-		"-e",
+	cmd, err := r.Command(
 		`f <- file("stdin")
     open(f)
 		while(length(line <- readLines(f, n = 1)) > 0) {
@@ -30,6 +27,10 @@ func (v *vapour) repl(in io.Reader, out io.Writer, er io.Writer) {
 		}`,
 	)
 
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	//cmd.Stdout = out
 	cmd.Stderr = er
 
@@ -0,0 +1,796 @@
+package rsource
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// S3 generics of base R whose methods are written as vapour methods
+var baseGenerics = []string{
+	"print", "format", "summary", "toString", "as.character", "as.list",
+	"length", "plot", "mean", "head", "tail", "c",
+}
+
+// first tokens of statements that are not returned
+var notReturned = []string{
+	"return", "if", "else", "for", "while", "repeat", "stop", "function", "break", "next",
+}
+
+// Skipped is code Convert leaves as is, to convert by hand
+type Skipped struct {
+	Line   int
+	Char   int
+	Reason string
+}
+
+type edit struct {
+	start int
+	end   int
+	text  string
+}
+
+// variables declared in a function
+type scope map[string]bool
+
+type frame struct {
+	closer string
+	// body of a function, its last statement is returned
+	fn bool
+	// index of the last statement started in the block
+	last int
+}
+
+type converter struct {
+	src   string
+	toks  []Token
+	edits []edit
+	// applied after the edits at the same place: returns after the
+	// braces opened there, the braces closing a function after those
+	// closing its branches
+	after    []edit
+	skipped  []Skipped
+	frames   []*frame
+	scopes   []scope
+	generics map[string]bool
+	// parameters of the function whose body opens next
+	pending scope
+	// bodies we put in braces, they start a statement
+	starts map[int]bool
+}
+
+// Convert rewrites R code as vapour: assignments become let,
+// functions become func with parameters of type `any`, S3
+// generics and methods use the method syntax, comments are kept.
+// The conversion is mechanical and is a starting point,
+// the code it cannot convert is returned as skipped.
+func Convert(src string) (string, []Skipped) {
+	c := &converter{
+		src:      src,
+		toks:     significant(Tokenize(src)),
+		scopes:   []scope{{}},
+		generics: make(map[string]bool),
+		starts:   make(map[int]bool),
+	}
+
+	for _, g := range baseGenerics {
+		c.generics[g] = true
+	}
+
+	c.findGenerics()
+	c.run()
+
+	c.edits = append(c.edits, c.after...)
+
+	return c.apply(), c.skipped
+}
+
+func (c *converter) findGenerics() {
+	for i := range c.toks {
+		if !startsStatement(c.toks, i) {
+			continue
+		}
+
+		name, _, close, ok := c.functionAssignment(i)
+
+		if ok && c.isGeneric(close) {
+			c.generics[name] = true
+		}
+	}
+}
+
+func (c *converter) run() {
+	for i := 0; i < len(c.toks); i++ {
+		t := c.toks[i]
+
+		if t.Kind == Operator {
+			switch t.Value {
+			case "(":
+				c.push(&frame{closer: ")", last: -1})
+				continue
+			case "[":
+				c.push(&frame{closer: "]", last: -1})
+				continue
+			case "{":
+				f := &frame{closer: "}", last: -1}
+
+				if c.pending != nil {
+					f.fn = true
+					c.scopes = append(c.scopes, c.pending)
+					c.pending = nil
+				}
+
+				c.push(f)
+				continue
+			case ")", "]", "}":
+				c.pop()
+				continue
+			case ":":
+				c.replace(t.Start, t.End, "..")
+				continue
+			}
+		}
+
+		if c.inBlock() && (startsStatement(c.toks, i) || c.starts[i]) {
+			// blank lines are not statements, else continues the if
+			if len(c.frames) > 0 && startsStatement(c.toks, i) && t.Kind != Newline && !isElse(t) {
+				c.frames[len(c.frames)-1].last = i
+			}
+
+			next, ok := c.statement(i)
+
+			if ok {
+				i = next
+				continue
+			}
+		}
+
+		if isFunction(t) && i+1 < len(c.toks) && c.toks[i+1].Value == "(" {
+			i = c.anonymous(i)
+			continue
+		}
+
+		if t.Kind == Ident && t.Value == "for" {
+			c.forLoop(i)
+		}
+
+		if t.Kind == Ident {
+			c.braces(i)
+		}
+	}
+}
+
+// braces puts the body of if, else, for, while and repeat
+// in braces when it has none, vapour requires them
+func (c *converter) braces(i int) {
+	switch c.toks[i].Value {
+	case "if", "for", "while":
+		if i+1 < len(c.toks) && c.toks[i+1].Value == "(" {
+			c.wrap(closing(c.toks, i+1) + 1)
+		}
+	case "else":
+		// vapour has no else if, the if goes in braces too
+		c.wrap(i + 1)
+	case "repeat":
+		// vapour has no repeat
+		c.replace(c.toks[i].Start, c.toks[i].End, "while (TRUE)")
+		c.wrap(i + 1)
+	}
+}
+
+func (c *converter) wrap(i int) {
+	j := skipNewlines(c.toks, i)
+
+	if j >= len(c.toks) || c.toks[j].Value == "{" {
+		return
+	}
+
+	end := branchEnd(c.toks, j)
+
+	if end <= j {
+		return
+	}
+
+	c.insert(c.toks[j].Start, "{ ")
+	c.insert(c.toks[end-1].End, " }")
+	c.starts[j] = true
+}
+
+func (c *converter) push(f *frame) {
+	c.frames = append(c.frames, f)
+}
+
+func (c *converter) pop() {
+	if len(c.frames) == 0 {
+		return
+	}
+
+	f := c.frames[len(c.frames)-1]
+	c.frames = c.frames[:len(c.frames)-1]
+
+	if !f.fn {
+		return
+	}
+
+	c.addReturn(f.last)
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// inBlock checks that we are at the top level or in braces,
+// not in the arguments of a call
+func (c *converter) inBlock() bool {
+	return len(c.frames) == 0 || c.frames[len(c.frames)-1].closer == "}"
+}
+
+func (c *converter) scope() scope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+// statement converts assignments, it returns the index
+// of the last token it handled
+func (c *converter) statement(i int) (int, bool) {
+	if _, _, close, ok := c.functionAssignment(i); ok {
+		if !isIdentifier(c.name(i)) {
+			c.skip(c.toks[i], "`"+c.name(i)+"` is not a valid function name")
+			return c.bodyEnd(close), true
+		}
+
+		return c.function(i), true
+	}
+
+	t := c.toks[i]
+
+	if t.Kind != Ident {
+		return i, false
+	}
+
+	j := c.targetEnd(i)
+
+	if j >= len(c.toks) || c.toks[j].Kind != Operator {
+		return i, false
+	}
+
+	op := c.toks[j]
+
+	if op.Value == "<<-" {
+		c.skip(t, "`<<-` has no equivalent")
+		return j, true
+	}
+
+	if op.Value != "<-" && op.Value != "=" {
+		return i, false
+	}
+
+	// names(x) <- value
+	if c.toks[i+1].Value == "(" {
+		c.skip(t, "replacement function `"+t.Value+"<-` has no equivalent")
+		return j, true
+	}
+
+	// x$name <- value, x[i] <- value
+	if j > i+1 {
+		c.replace(op.Start, op.End, "=")
+		return j, true
+	}
+
+	if !isIdentifier(t.Value) {
+		c.skip(t, "`"+t.Value+"` is not a valid variable name")
+		return j, true
+	}
+
+	if c.scope()[t.Value] {
+		c.replace(op.Start, op.End, "=")
+		return j, true
+	}
+
+	c.scope()[t.Value] = true
+	c.replace(t.Start, op.End, "let "+t.Value+": any =")
+
+	return j, true
+}
+
+// targetEnd returns the index after the target of an
+// assignment starting at i, e.g. x$name, x[i] or names(x)
+func (c *converter) targetEnd(i int) int {
+	j := i + 1
+	for j < len(c.toks) {
+		v := c.toks[j].Value
+
+		if (v == "$" || v == "@") && j+1 < len(c.toks) && (c.toks[j+1].Kind == Ident || c.toks[j+1].Kind == String) {
+			j += 2
+			continue
+		}
+
+		// calls are only targets as replacement functions
+		if v == "[" || (v == "(" && j == i+1) {
+			j = closing(c.toks, j) + 1
+			continue
+		}
+
+		return j
+	}
+
+	return j
+}
+
+// functionAssignment matches name <- function(...), it returns
+// the indices of the function keyword and closing parenthesis
+func (c *converter) functionAssignment(i int) (string, int, int, bool) {
+	name := c.toks[i]
+
+	if name.Kind != Ident && name.Kind != String {
+		return "", 0, 0, false
+	}
+
+	j := skipNewlines(c.toks, i+1)
+
+	if j >= len(c.toks) || c.toks[j].Kind != Operator || (c.toks[j].Value != "<-" && c.toks[j].Value != "=") {
+		return "", 0, 0, false
+	}
+
+	j = skipNewlines(c.toks, j+1)
+
+	if j+1 >= len(c.toks) || !isFunction(c.toks[j]) || c.toks[j+1].Value != "(" {
+		return "", 0, 0, false
+	}
+
+	_, close := formals(c.src, c.toks, j+1)
+
+	return c.name(i), j, close, true
+}
+
+// name of the variable or function assigned at i, unquoted
+func (c *converter) name(i int) string {
+	return strings.Trim(c.toks[i].Value, "\"'")
+}
+
+// isGeneric checks whether the body after the formals
+// only dispatches with UseMethod
+func (c *converter) isGeneric(close int) bool {
+	j := skipNewlines(c.toks, close+1)
+
+	if j < len(c.toks) && c.toks[j].Value == "{" {
+		j = skipNewlines(c.toks, j+1)
+	}
+
+	return j < len(c.toks) && c.toks[j].Kind == Ident && c.toks[j].Value == "UseMethod"
+}
+
+// function converts a named function, generic or method
+func (c *converter) function(i int) int {
+	name, fn, close, _ := c.functionAssignment(i)
+	params, _ := formals(c.src, c.toks, fn+1)
+	start := c.toks[i].Start
+
+	c.scope()[name] = true
+
+	if c.isGeneric(close) {
+		end := c.bodyEnd(close)
+		c.replace(start, c.toks[end].End, "@generic\nfunc "+receiver(params, "any")+name+"("+parameters(tail(params))+"): any")
+		return end
+	}
+
+	generic, class := c.method(name)
+
+	if generic != "" && len(params) > 0 {
+		c.replace(start, c.toks[close].End, "func "+receiver(params, class)+generic+"("+parameters(tail(params))+"): any")
+	} else {
+		c.replace(start, c.toks[close].End, "func "+name+"("+parameters(params)+"): any")
+	}
+
+	c.body(close, params, "\n"+lineIndent(c.src, start))
+
+	return close
+}
+
+// anonymous converts function(x) and \(x) to (x: any): any =>
+func (c *converter) anonymous(i int) int {
+	params, close := formals(c.src, c.toks, i+1)
+
+	if close >= len(c.toks) {
+		return close
+	}
+
+	c.replace(c.toks[i].Start, c.toks[close].End, "("+parameters(params)+"): any =>")
+	c.body(close, params, " ")
+
+	return close
+}
+
+// body prepares the body following the formals: braces are
+// handled when walked, expressions are wrapped in braces
+func (c *converter) body(close int, params []Param, sep string) {
+	j := skipNewlines(c.toks, close+1)
+
+	if j >= len(c.toks) {
+		return
+	}
+
+	s := scope{}
+	for _, p := range params {
+		s[p.Name] = true
+	}
+
+	if c.toks[j].Value == "{" {
+		c.pending = s
+		return
+	}
+
+	end := expressionEnd(c.toks, j)
+
+	if end <= j {
+		return
+	}
+
+	inner := sep
+	if sep != " " {
+		inner = sep + "  "
+	}
+
+	c.insert(c.toks[j].Start, "{"+inner)
+	c.after = append(c.after, edit{start: c.toks[end-1].End, end: c.toks[end-1].End, text: sep + "}"})
+	c.addReturn(j)
+}
+
+// bodyEnd returns the index of the last token of the body
+func (c *converter) bodyEnd(close int) int {
+	j := skipNewlines(c.toks, close+1)
+
+	if j < len(c.toks) && c.toks[j].Value == "{" {
+		depth := 0
+		for ; j < len(c.toks); j++ {
+			switch c.toks[j].Value {
+			case "{", "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+
+			if depth == 0 {
+				return j
+			}
+		}
+		return len(c.toks) - 1
+	}
+
+	return expressionEnd(c.toks, j) - 1
+}
+
+// method splits generic.class for known generics
+func (c *converter) method(name string) (string, string) {
+	generic := ""
+	for i := range name {
+		if name[i] != '.' || i == 0 || i == len(name)-1 {
+			continue
+		}
+
+		if c.generics[name[:i]] {
+			generic = name[:i]
+		}
+	}
+
+	if generic == "" {
+		return "", ""
+	}
+
+	return generic, name[len(generic)+1:]
+}
+
+// forLoop declares the variable of for (x in y)
+func (c *converter) forLoop(i int) {
+	if i+3 >= len(c.toks) || c.toks[i+1].Value != "(" || c.toks[i+3].Value != "in" {
+		return
+	}
+
+	v := c.toks[i+2]
+	c.insert(v.Start, "let ")
+	c.insert(v.End, ": any")
+}
+
+func (c *converter) addReturn(last int) {
+	if last < 0 || last >= len(c.toks) {
+		return
+	}
+
+	t := c.toks[last]
+
+	if t.Kind == Ident && t.Value == "if" {
+		c.returnBranches(last)
+		return
+	}
+
+	// values such as -1, !x or (x)
+	if t.Kind == Operator && !strings.Contains("-+!(", t.Value) {
+		return
+	}
+
+	for _, k := range notReturned {
+		if t.Kind == Ident && t.Value == k {
+			return
+		}
+	}
+
+	// assignments are not returned
+	if last+1 < len(c.toks) && c.toks[last+1].Kind == Operator && isAssign(c.toks[last+1].Value) {
+		return
+	}
+
+	c.after = append(c.after, edit{start: t.Start, end: t.Start, text: "return "})
+}
+
+// returnBranches returns the last value of each branch
+// of the if starting at i
+func (c *converter) returnBranches(i int) {
+	if i+1 >= len(c.toks) || c.toks[i+1].Value != "(" {
+		return
+	}
+
+	end := c.returnBranch(closing(c.toks, i+1) + 1)
+
+	// the else of a braced branch may be on the next line
+	j := end
+	if end > 0 && c.toks[end-1].Value == "}" {
+		j = skipNewlines(c.toks, end)
+	}
+
+	if j >= len(c.toks) || !isElse(c.toks[j]) {
+		return
+	}
+
+	next := skipNewlines(c.toks, j+1)
+
+	if next < len(c.toks) && c.toks[next].Value == "if" {
+		c.returnBranches(next)
+		return
+	}
+
+	c.returnBranch(j + 1)
+}
+
+// returnBranch returns the last value of the body starting
+// at i, it returns the index after the body
+func (c *converter) returnBranch(i int) int {
+	j := skipNewlines(c.toks, i)
+
+	if j >= len(c.toks) {
+		return j
+	}
+
+	if c.toks[j].Value != "{" {
+		c.addReturn(j)
+		return branchEnd(c.toks, j)
+	}
+
+	end := closing(c.toks, j)
+	c.addReturn(c.lastStatement(j, end))
+
+	return end + 1
+}
+
+// lastStatement returns the index of the last statement
+// in the braces, -1 if there is none
+func (c *converter) lastStatement(open, close int) int {
+	last := -1
+	depth := 0
+	for j := open + 1; j < close && j < len(c.toks); j++ {
+		t := c.toks[j]
+
+		if t.Kind == Operator && strings.Contains(")]}", t.Value) {
+			depth--
+			continue
+		}
+
+		if depth == 0 && startsStatement(c.toks, j) && t.Kind != Newline && !isElse(t) {
+			last = j
+		}
+
+		if t.Kind == Operator && strings.Contains("([{", t.Value) {
+			depth++
+		}
+	}
+
+	return last
+}
+
+func (c *converter) skip(t Token, reason string) {
+	c.skipped = append(c.skipped, Skipped{Line: t.Line, Char: t.Char, Reason: reason})
+}
+
+func (c *converter) replace(start, end int, text string) {
+	c.edits = append(c.edits, edit{start: start, end: end, text: text})
+}
+
+func (c *converter) insert(pos int, text string) {
+	c.replace(pos, pos, text)
+}
+
+func (c *converter) apply() string {
+	sort.SliceStable(c.edits, func(i, j int) bool {
+		return c.edits[i].start < c.edits[j].start
+	})
+
+	var out strings.Builder
+	pos := 0
+	for _, e := range c.edits {
+		// overlapping edits are dropped
+		if e.start < pos {
+			continue
+		}
+
+		out.WriteString(c.src[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+
+	out.WriteString(c.src[pos:])
+
+	return out.String()
+}
+
+// expressionEnd returns the index after the expression starting at i
+func expressionEnd(tokens []Token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+
+		if t.Kind == Newline && depth == 0 && !continues(tokens, i) {
+			return i
+		}
+
+		if t.Kind != Operator {
+			continue
+		}
+
+		switch t.Value {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ",", ";":
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return i
+}
+
+// closing returns the index of the bracket closing the one at i
+func closing(tokens []Token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		if tokens[i].Kind != Operator {
+			continue
+		}
+
+		switch tokens[i].Value {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+
+		if depth == 0 {
+			return i
+		}
+	}
+
+	return i
+}
+
+// isIdentifier checks that the name is valid in vapour,
+// R allows any name between backticks
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if c == '_' || c == '.' || unicode.IsLetter(c) {
+			continue
+		}
+
+		if i > 0 && unicode.IsDigit(c) {
+			continue
+		}
+
+		return false
+	}
+
+	return name != ""
+}
+
+// branchEnd returns the index after the body of a branch
+// starting at i, the body of if (x) 1 else 2 ends before else
+func branchEnd(tokens []Token, i int) int {
+	end := expressionEnd(tokens, i)
+
+	depth := 0
+	ifs := 0
+	for j := i; j < end; j++ {
+		t := tokens[j]
+
+		if t.Kind == Operator && strings.Contains("([{", t.Value) {
+			depth++
+		}
+
+		if t.Kind == Operator && strings.Contains(")]}", t.Value) {
+			depth--
+		}
+
+		if depth != 0 || t.Kind != Ident {
+			continue
+		}
+
+		// else of a nested if
+		if t.Value == "if" {
+			ifs++
+		}
+
+		if t.Value == "else" {
+			if ifs == 0 {
+				return j
+			}
+			ifs--
+		}
+	}
+
+	return end
+}
+
+func isElse(t Token) bool {
+	return t.Kind == Ident && t.Value == "else"
+}
+
+// continues checks whether the line ends with a binary operator
+func continues(tokens []Token, newline int) bool {
+	if newline == 0 {
+		return false
+	}
+
+	prev := tokens[newline-1]
+
+	return prev.Kind == Operator && !strings.Contains(")]}", prev.Value)
+}
+
+func receiver(params []Param, class string) string {
+	if len(params) == 0 {
+		return "(x: " + class + ") "
+	}
+
+	return "(" + params[0].Name + ": " + class + ") "
+}
+
+func tail(params []Param) []Param {
+	if len(params) == 0 {
+		return params
+	}
+
+	return params[1:]
+}
+
+func parameters(params []Param) string {
+	var str []string
+	for _, p := range params {
+		param := p.Name + ": any"
+
+		if p.HasDefault {
+			param += " = " + p.Default
+		}
+
+		str = append(str, param)
+	}
+
+	return strings.Join(str, ", ")
+}
+
+// lineIndent returns the indentation of the line at pos
+func lineIndent(src string, pos int) string {
+	start := strings.LastIndex(src[:pos], "\n") + 1
+	end := start
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+
+	return src[start:end]
+}
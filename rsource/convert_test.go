@@ -0,0 +1,272 @@
+package rsource
+
+import (
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	code := `# helpers
+add <- function(x, y = 2) {
+  # sum
+  z <- x + y
+  z <- z * 2
+  z
+}
+
+inc <- function(x) x + 1
+
+area <- function(shape, ...) UseMethod("area")
+
+area.square <- function(shape, ...) {
+  shape$side * shape$side
+}
+
+print.square <- function(x, ...) {
+  cat("square\n")
+}
+
+res <- lapply(1:10, function(i) i * 2)
+
+for (i in 1:3) {
+  print(i)
+}
+`
+
+	expected := `# helpers
+func add(x: any, y: any = 2): any {
+  # sum
+  let z: any = x + y
+  z = z * 2
+  return z
+}
+
+func inc(x: any): any {
+  return x + 1
+}
+
+@generic
+func (shape: any) area(...: any): any
+
+func (shape: square) area(...: any): any {
+  return shape$side * shape$side
+}
+
+func (x: square) print(...: any): any {
+  return cat("square\n")
+}
+
+let res: any = lapply(1..10, (i: any): any => { return i * 2 })
+
+for (let i: any in 1..3) {
+  print(i)
+}
+`
+
+	actual, skipped := Convert(code)
+
+	if actual != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, actual)
+	}
+
+	if len(skipped) != 0 {
+		t.Fatalf("expected nothing skipped, got %v", skipped)
+	}
+}
+
+func TestConvertScopes(t *testing.T) {
+	code := `x <- 1
+f <- function(x) {
+  x <- x + 1
+  y = 2
+  if (x > y) {
+    return(x)
+  }
+  y
+}
+x <- 2
+`
+
+	expected := `let x: any = 1
+func f(x: any): any {
+  x = x + 1
+  let y: any = 2
+  if (x > y) {
+    return(x)
+  }
+  return y
+}
+x = 2
+`
+
+	actual, skipped := Convert(code)
+
+	if actual != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, actual)
+	}
+
+	if len(skipped) != 0 {
+		t.Fatalf("expected nothing skipped, got %v", skipped)
+	}
+}
+
+func TestConvertTrailingBlocks(t *testing.T) {
+	code := `f <- function(x) {
+  if (x) {
+    1
+  } else {
+    FALSE
+  }
+}
+
+g <- function(x) { if (x) { 1 } else { 2 } }
+
+h <- function(x) {
+  repeat {
+    x <- x + 1
+    if (x > 10) break
+  }
+
+}
+
+k <- function(x) {
+  if (x > 1) {
+    y <- 1
+    "big"
+  } else if (x > 0) {
+    return("small")
+  } else {
+    stop("negative")
+  }
+}
+`
+
+	expected := `func f(x: any): any {
+  if (x) {
+    return 1
+  } else {
+    return FALSE
+  }
+}
+
+func g(x: any): any { if (x) { return 1 } else { return 2 } }
+
+func h(x: any): any {
+  while (TRUE) {
+    x = x + 1
+    if (x > 10) { break }
+  }
+
+}
+
+func k(x: any): any {
+  if (x > 1) {
+    let y: any = 1
+    return "big"
+  } else { if (x > 0) {
+    return("small")
+  } else {
+    stop("negative")
+  } }
+}
+`
+
+	actual, _ := Convert(code)
+
+	if actual != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, actual)
+	}
+}
+
+func TestConvertBraces(t *testing.T) {
+	code := `for (i in 1:10) print(i)
+
+i <- 10
+while (i > 0) i <- i - 1
+
+if (i > 2) print("a") else print("b")
+
+if (i > 2)
+  print("a")
+
+repeat break
+
+sign <- function(x) if (x > 0) 1 else if (x < 0) -1 else 0
+
+f <- function(x) {
+  if (x) "yes" else FALSE
+}
+`
+
+	expected := `for (let i: any in 1..10) { print(i) }
+
+let i: any = 10
+while (i > 0) { i = i - 1 }
+
+if (i > 2) { print("a") } else { print("b") }
+
+if (i > 2)
+  { print("a") }
+
+while (TRUE) { break }
+
+func sign(x: any): any {
+  if (x > 0) { return 1 } else { if (x < 0) { return -1 } else { return 0 } }
+}
+
+func f(x: any): any {
+  if (x) { return "yes" } else { return FALSE }
+}
+`
+
+	actual, skipped := Convert(code)
+
+	if actual != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, actual)
+	}
+
+	if len(skipped) != 0 {
+		t.Fatalf("expected nothing skipped, got %v", skipped)
+	}
+}
+
+func TestConvertTargets(t *testing.T) {
+	code := `z <- list(a = 1)
+z$a <- 2
+z[["b"]] <- 3
+z@c = 4
+names(z) <- c("a", "b")
+` + "`" + `my fun` + "`" + ` <- function(a) a
+` + "`" + `inc` + "`" + ` <- function(x) x + 1
+` + "`" + `my var` + "`" + ` <- 1
+`
+
+	expected := `let z: any = list(a = 1)
+z$a = 2
+z[["b"]] = 3
+z@c = 4
+names(z) <- c("a", "b")
+` + "`" + `my fun` + "`" + ` <- function(a) a
+func inc(x: any): any {
+  return x + 1
+}
+` + "`" + `my var` + "`" + ` <- 1
+`
+
+	actual, skipped := Convert(code)
+
+	if actual != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, actual)
+	}
+
+	lines := []int{4, 5, 7}
+
+	if len(skipped) != len(lines) {
+		t.Fatalf("expected %v skipped, got %v", len(lines), skipped)
+	}
+
+	for i, l := range lines {
+		if skipped[i].Line != l {
+			t.Fatalf("expected line %v skipped, got %v", l, skipped[i])
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package rsource
+
+import "strings"
+
+// Generated starts the files written by vapour,
+// these are not hand-written R
+const Generated = "# GENERATED BY VAPOUR"
+
+// IsGenerated checks whether the R code was written by vapour
+func IsGenerated(src string) bool {
+	return strings.HasPrefix(src, Generated)
+}
+
+type Param struct {
+	Name string
+	// source of the default value, if any
+	Default    string
+	HasDefault bool
+}
+
+// Function is a function defined at the top level
+type Function struct {
+	Name   string
+	Line   int
+	Char   int
+	Params []Param
+}
+
+// Functions finds the functions assigned at the top level
+// of the code: name <- function(...), name = function(...)
+// and name <- \(...)
+func Functions(src string) []Function {
+	tokens := significant(Tokenize(src))
+
+	var fns []Function
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if tok.Kind == Operator && strings.Contains("([{", tok.Value) {
+			depth++
+			continue
+		}
+
+		if tok.Kind == Operator && strings.Contains(")]}", tok.Value) {
+			depth--
+			continue
+		}
+
+		if depth != 0 || !startsStatement(tokens, i) {
+			continue
+		}
+
+		fn, end, ok := function(src, tokens, i)
+
+		if !ok {
+			continue
+		}
+
+		fns = append(fns, fn)
+		i = end
+	}
+
+	return fns
+}
+
+// significant drops comments
+func significant(tokens []Token) []Token {
+	var sig []Token
+	for _, t := range tokens {
+		if t.Kind == Comment {
+			continue
+		}
+		sig = append(sig, t)
+	}
+	return sig
+}
+
+func startsStatement(tokens []Token, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	prev := tokens[i-1]
+
+	return prev.Kind == Newline || (prev.Kind == Operator && (prev.Value == ";" || prev.Value == "{" || prev.Value == "}"))
+}
+
+// function reads the definition starting at i, it returns
+// the index of the closing parenthesis of the formals
+func function(src string, tokens []Token, i int) (Function, int, bool) {
+	var fn Function
+
+	name := tokens[i]
+
+	if name.Kind != Ident && name.Kind != String {
+		return fn, i, false
+	}
+
+	j := skipNewlines(tokens, i+1)
+
+	if j >= len(tokens) || tokens[j].Kind != Operator || !isAssign(tokens[j].Value) {
+		return fn, i, false
+	}
+
+	// the function may start on the next line
+	j = skipNewlines(tokens, j+1)
+
+	if j >= len(tokens) || !isFunction(tokens[j]) {
+		return fn, i, false
+	}
+
+	j++
+
+	if j >= len(tokens) || tokens[j].Value != "(" {
+		return fn, i, false
+	}
+
+	fn.Name = strings.Trim(name.Value, "\"'")
+	fn.Line = name.Line
+	fn.Char = name.Char
+
+	params, end := formals(src, tokens, j)
+	fn.Params = params
+
+	return fn, end, true
+}
+
+// formals reads the parameters from the opening parenthesis at i
+func formals(src string, tokens []Token, i int) ([]Param, int) {
+	var params []Param
+
+	i++
+	for i < len(tokens) {
+		tok := tokens[i]
+
+		if tok.Kind == Newline || tok.Value == "," {
+			i++
+			continue
+		}
+
+		if tok.Value == ")" {
+			return params, i
+		}
+
+		param := Param{Name: tok.Value}
+		i = skipNewlines(tokens, i+1)
+
+		if i < len(tokens) && tokens[i].Value == "=" {
+			start := skipNewlines(tokens, i+1)
+			end := defaultEnd(tokens, start)
+
+			if start < end {
+				param.Default = strings.TrimSpace(src[tokens[start].Start:tokens[end-1].End])
+				param.HasDefault = true
+			}
+
+			i = end
+		}
+
+		params = append(params, param)
+	}
+
+	return params, i
+}
+
+// defaultEnd returns the index of the comma or parenthesis
+// closing the default value starting at i
+func defaultEnd(tokens []Token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		v := tokens[i].Value
+
+		if tokens[i].Kind != Operator {
+			continue
+		}
+
+		if depth == 0 && (v == "," || v == ")") {
+			return i
+		}
+
+		if strings.Contains("([{", v) {
+			depth++
+		}
+
+		if strings.Contains(")]}", v) {
+			depth--
+		}
+	}
+
+	return i
+}
+
+func skipNewlines(tokens []Token, i int) int {
+	for i < len(tokens) && tokens[i].Kind == Newline {
+		i++
+	}
+	return i
+}
+
+func isAssign(op string) bool {
+	return op == "<-" || op == "=" || op == "<<-"
+}
+
+func isFunction(tok Token) bool {
+	return (tok.Kind == Ident && tok.Value == "function") || (tok.Kind == Operator && tok.Value == "\\")
+}
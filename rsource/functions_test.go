@@ -0,0 +1,85 @@
+package rsource
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	code := `x <- c(1L, 0x1F, 1e-3) # comment
+y <<- 'it\'s' %in% r"(raw "string")"
+`
+
+	tokens := Tokenize(code)
+
+	kinds := []Kind{
+		Ident, Operator, Ident, Operator, Number, Operator, Number, Operator, Number, Operator, Comment, Newline,
+		Ident, Operator, String, Operator, String, Newline,
+	}
+
+	if len(tokens) != len(kinds) {
+		t.Fatalf("expected %v tokens, got %v: %v", len(kinds), len(tokens), tokens)
+	}
+
+	for i, k := range kinds {
+		if tokens[i].Kind != k {
+			t.Fatalf("token %v `%v`: expected kind %v, got %v", i, tokens[i].Value, k, tokens[i].Kind)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	code := `# helpers
+add <- function(x, y = 1) {
+  inner <- function(z) z
+  x + y
+}
+
+"quoted" = function(...) NULL
+
+lambda <-
+  \(x, sep = c(",", ";")) paste(x, collapse = sep[1])
+
+obj$method <- function(x) x
+lst <- list(fn = function(x) x)
+`
+
+	fns := Functions(code)
+
+	expected := []Function{
+		{Name: "add", Line: 1, Params: []Param{{Name: "x"}, {Name: "y", Default: "1", HasDefault: true}}},
+		{Name: "quoted", Line: 6, Params: []Param{{Name: "..."}}},
+		{Name: "lambda", Line: 8, Params: []Param{{Name: "x"}, {Name: "sep", Default: `c(",", ";")`, HasDefault: true}}},
+	}
+
+	if len(fns) != len(expected) {
+		t.Fatalf("expected %v functions, got %v: %v", len(expected), len(fns), fns)
+	}
+
+	for i, e := range expected {
+		fn := fns[i]
+
+		if fn.Name != e.Name || fn.Line != e.Line {
+			t.Fatalf("expected `%v` on line %v, got `%v` on line %v", e.Name, e.Line, fn.Name, fn.Line)
+		}
+
+		if len(fn.Params) != len(e.Params) {
+			t.Fatalf("`%v`: expected %v parameters, got %v", e.Name, len(e.Params), len(fn.Params))
+		}
+
+		for j, p := range e.Params {
+			if fn.Params[j] != p {
+				t.Fatalf("`%v`: expected parameter %v, got %v", e.Name, p, fn.Params[j])
+			}
+		}
+	}
+}
+
+func TestGenerated(t *testing.T) {
+	if !IsGenerated(Generated + "\n# DO NOT EDIT\nx <- 1") {
+		t.Fatal("expected generated code")
+	}
+
+	if IsGenerated("x <- 1\n" + Generated) {
+		t.Fatal("expected hand-written code")
+	}
+}
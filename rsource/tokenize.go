@@ -0,0 +1,245 @@
+package rsource
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Kind int
+
+const (
+	Ident Kind = iota
+	Number
+	String
+	Comment
+	Operator
+	Newline
+)
+
+// Token of R code, lines and characters start at 0
+type Token struct {
+	Kind  Kind
+	Value string
+	Line  int
+	Char  int
+	// offsets of the token in the code
+	Start int
+	End   int
+}
+
+// operators, longest first
+var operators = []string{
+	"<<-", "->>", ":::",
+	"<-", "->", "<=", ">=", "==", "!=", "&&", "||", "|>", "::",
+	"+", "-", "*", "/", "^", "<", ">", "!", "&", "|", "~", "?", ":",
+	"=", "$", "@", "(", ")", "{", "}", "[", "]", ",", ";", "\\",
+}
+
+type tokenizer struct {
+	src    string
+	pos    int
+	line   int
+	char   int
+	tokens []Token
+}
+
+// Tokenize splits R code in tokens, it does not validate the code
+func Tokenize(src string) []Token {
+	t := &tokenizer{src: src}
+
+	for t.pos < len(t.src) {
+		t.next()
+	}
+
+	return t.tokens
+}
+
+func (t *tokenizer) peek(offset int) byte {
+	if t.pos+offset >= len(t.src) {
+		return 0
+	}
+
+	return t.src[t.pos+offset]
+}
+
+func (t *tokenizer) emit(kind Kind, start, line, char int) {
+	t.tokens = append(t.tokens, Token{
+		Kind:  kind,
+		Value: t.src[start:t.pos],
+		Line:  line,
+		Char:  char,
+		Start: start,
+		End:   t.pos,
+	})
+}
+
+// advance moves over n bytes, keeping track of lines
+func (t *tokenizer) advance(n int) {
+	for i := 0; i < n && t.pos < len(t.src); i++ {
+		if t.src[t.pos] == '\n' {
+			t.line++
+			t.char = 0
+		} else {
+			t.char++
+		}
+		t.pos++
+	}
+}
+
+func (t *tokenizer) next() {
+	start, line, char := t.pos, t.line, t.char
+	c := t.peek(0)
+
+	switch {
+	case c == '\n':
+		t.advance(1)
+		t.emit(Newline, start, line, char)
+	case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+		t.advance(1)
+	case c == '#':
+		for t.pos < len(t.src) && t.peek(0) != '\n' {
+			t.advance(1)
+		}
+		t.emit(Comment, start, line, char)
+	case (c == 'r' || c == 'R') && (t.peek(1) == '"' || t.peek(1) == '\''):
+		t.rawString()
+		t.emit(String, start, line, char)
+	case c == '"' || c == '\'':
+		t.quoted(c)
+		t.emit(String, start, line, char)
+	case c == '`':
+		t.quoted(c)
+		t.tokens = append(t.tokens, Token{
+			Kind:  Ident,
+			Value: strings.Trim(t.src[start:t.pos], "`"),
+			Line:  line,
+			Char:  char,
+			Start: start,
+			End:   t.pos,
+		})
+	case isDigit(c) || (c == '.' && isDigit(t.peek(1))):
+		t.number()
+		t.emit(Number, start, line, char)
+	case c == '.' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)):
+		t.identifier()
+
+		// not a letter, we move on
+		if t.pos == start {
+			t.advance(1)
+			return
+		}
+
+		t.emit(Ident, start, line, char)
+	case c == '%':
+		t.advance(1)
+		for t.pos < len(t.src) && t.peek(0) != '%' && t.peek(0) != '\n' {
+			t.advance(1)
+		}
+		t.advance(1)
+		t.emit(Operator, start, line, char)
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(t.src[t.pos:], op) {
+				t.advance(len(op))
+				t.emit(Operator, start, line, char)
+				return
+			}
+		}
+
+		// unknown character, we move on
+		t.advance(1)
+	}
+}
+
+func (t *tokenizer) quoted(quote byte) {
+	t.advance(1)
+
+	for t.pos < len(t.src) {
+		c := t.peek(0)
+
+		if c == '\\' {
+			t.advance(2)
+			continue
+		}
+
+		t.advance(1)
+
+		if c == quote {
+			return
+		}
+	}
+}
+
+// rawString reads r"(...)", r"[...]" or r"{...}" with optional dashes
+func (t *tokenizer) rawString() {
+	quote := t.peek(1)
+	t.advance(2)
+
+	dashes := 0
+	for t.peek(0) == '-' {
+		dashes++
+		t.advance(1)
+	}
+
+	closing := map[byte]byte{'(': ')', '[': ']', '{': '}'}[t.peek(0)]
+	end := string(closing) + strings.Repeat("-", dashes) + string(quote)
+
+	i := strings.Index(t.src[t.pos:], end)
+
+	if closing == 0 || i < 0 {
+		t.advance(len(t.src) - t.pos)
+		return
+	}
+
+	t.advance(i + len(end))
+}
+
+func (t *tokenizer) number() {
+	if t.peek(0) == '0' && (t.peek(1) == 'x' || t.peek(1) == 'X') {
+		t.advance(2)
+		for isHex(t.peek(0)) {
+			t.advance(1)
+		}
+	}
+
+	for isDigit(t.peek(0)) || t.peek(0) == '.' {
+		t.advance(1)
+	}
+
+	if t.peek(0) == 'e' || t.peek(0) == 'E' {
+		t.advance(1)
+		if t.peek(0) == '+' || t.peek(0) == '-' {
+			t.advance(1)
+		}
+		for isDigit(t.peek(0)) {
+			t.advance(1)
+		}
+	}
+
+	if t.peek(0) == 'L' || t.peek(0) == 'i' {
+		t.advance(1)
+	}
+}
+
+func (t *tokenizer) identifier() {
+	for t.pos < len(t.src) {
+		r, size := utf8.DecodeRuneInString(t.src[t.pos:])
+
+		if r != '.' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return
+		}
+
+		// a multi-byte rune counts as one character
+		t.pos += size
+		t.char++
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 
 	"github.com/vapourlang/vapour/cli"
 	"github.com/vapourlang/vapour/config"
@@ -15,13 +14,11 @@ import (
 )
 
 func run(code string) {
-	cmd := exec.Command(
-		"R",
-		"--no-save",
-		"--slave",
-		"-e",
-		code,
-	)
+	cmd, err := r.Command(code)
+
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	output, err := cmd.CombinedOutput()
 
@@ -35,10 +32,64 @@ func run(code string) {
 func (v *vapour) Run(args cli.CLI) {
 	v.config = config.ReadConfig()
 
+	// formatting does not need R
+	if *args.Fmt != "" {
+		v.format(*args.Fmt, *args.FmtCheck, *args.FmtWrite)
+		return
+	}
+
+	if *args.Traceback != "" {
+		v.traceback(*args.Traceback, *args.Outdir, *args.Outfile)
+		return
+	}
+
+	if *args.Offline || v.config.Offline {
+		r.SetOffline(true)
+	}
+
+	if !*args.Offline && !v.config.Offline && v.config.R != nil {
+		err := r.Setup(v.config.R.Path, v.config.R.Libs)
+
+		// running code requires R, checking does not
+		if err != nil && (*args.Repl || *args.Run || *args.Devtools != "" || *args.Stub != "") {
+			log.Fatal(err)
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v, R checks are skipped\n", err)
+			r.SetOffline(true)
+		}
+	}
+
+	// without a cache on disk R is queried on every run
+	r.OpenCache()
+	defer r.SaveCache()
+
 	environment.SetLibrary(r.LibPath())
+	environment.SetRegistries(environment.DefaultRegistries())
+
+	if *args.Stub != "" {
+		v.stub(*args.Stub)
+		return
+	}
+
+	if *args.Migrate != "" {
+		v.migrate(*args.Migrate)
+		return
+	}
+
+	if *args.Indir != "" && *args.Watch {
+		v.watch(args)
+		return
+	}
 
 	if *args.Indir != "" {
-		ok := v.transpile(args)
+		ok, err := v.transpile(args)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
 		devtools.Run(ok, args)
 		return
 	}
@@ -0,0 +1,116 @@
+package sourcemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Ext is appended to the path of the generated file
+const Ext = ".map"
+
+// Map relates the lines of a generated R file to the vapour code
+type Map struct {
+	// path of the generated file
+	File  string `json:"file"`
+	Lines []Line `json:"lines"`
+}
+
+// Line is the origin of a generated line, lines and
+// characters start at 1 as they do in R and in editors
+type Line struct {
+	Line       int    `json:"line"`
+	Source     string `json:"source"`
+	SourceLine int    `json:"sourceLine"`
+	SourceChar int    `json:"sourceChar"`
+}
+
+// Path returns the path of the map of the generated file
+func Path(file string) string {
+	return file + Ext
+}
+
+// Shift moves the generated lines down, e.g. when a header
+// is written before the code
+func (m *Map) Shift(lines int) {
+	for i := range m.Lines {
+		m.Lines[i].Line += lines
+	}
+}
+
+// Lookup returns the origin of the generated line, lines
+// without one, e.g. closing braces, take that of the
+// closest line before them
+func (m Map) Lookup(line int) (Line, bool) {
+	i := sort.Search(len(m.Lines), func(i int) bool {
+		return m.Lines[i].Line > line
+	})
+
+	if i == 0 {
+		return Line{}, false
+	}
+
+	return m.Lines[i-1], true
+}
+
+// Write saves the map next to the generated file
+func (m Map) Write() error {
+	data, err := json.Marshal(m)
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(Path(m.File), data, 0644)
+}
+
+// Read loads the map of the generated file
+func Read(file string) (Map, error) {
+	var m Map
+
+	data, err := os.ReadFile(Path(file))
+
+	if err != nil {
+		return m, err
+	}
+
+	err = json.Unmarshal(data, &m)
+
+	return m, err
+}
+
+// Rewrite replaces the locations in generated files found in R
+// output, e.g. tracebacks (vapour.R#12) or R CMD check notes
+// (R/vapour.R:12:3), with the locations in the vapour files
+func Rewrite(text string, maps []Map) string {
+	for _, m := range maps {
+		text = m.rewrite(text)
+	}
+
+	return text
+}
+
+func (m Map) rewrite(text string) string {
+	re := regexp.MustCompile(
+		`(^|[\s'"(\[])(?:[^\s'"()\[\]]*[/\\])?` +
+			regexp.QuoteMeta(filepath.Base(m.File)) +
+			`(?:#|:)(\d+)(?::\d+)?`,
+	)
+
+	return re.ReplaceAllStringFunc(text, func(match string) string {
+		sub := re.FindStringSubmatch(match)
+		line, _ := strconv.Atoi(sub[2])
+
+		origin, ok := m.Lookup(line)
+
+		if !ok {
+			return match
+		}
+
+		return fmt.Sprintf("%v%v:%v:%v", sub[1], origin.Source, origin.SourceLine, origin.SourceChar)
+	})
+}
@@ -0,0 +1,108 @@
+package sourcemap
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func testMap() Map {
+	return Map{
+		File: "R/vapour.R",
+		Lines: []Line{
+			{Line: 3, Source: "main.vp", SourceLine: 1, SourceChar: 1},
+			{Line: 5, Source: "main.vp", SourceLine: 3, SourceChar: 1},
+			{Line: 6, Source: "main.vp", SourceLine: 4, SourceChar: 3},
+		},
+	}
+}
+
+func TestLookup(t *testing.T) {
+	m := testMap()
+
+	if _, ok := m.Lookup(1); ok {
+		t.Fatal("header should not have an origin")
+	}
+
+	l, ok := m.Lookup(6)
+
+	if !ok || l.SourceLine != 4 || l.SourceChar != 3 {
+		t.Fatalf("unexpected origin of line 6: %+v", l)
+	}
+
+	// closing brace takes the origin of the line before
+	l, ok = m.Lookup(7)
+
+	if !ok || l.SourceLine != 4 {
+		t.Fatalf("unexpected origin of line 7: %+v", l)
+	}
+}
+
+func TestShift(t *testing.T) {
+	m := testMap()
+	m.Shift(2)
+
+	if m.Lines[0].Line != 5 {
+		t.Fatalf("expected line 5, got %v", m.Lines[0].Line)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	maps := []Map{testMap()}
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "2: stop(\"big\") at vapour.R#6",
+			out: "2: stop(\"big\") at main.vp:4:3",
+		},
+		{
+			in:  "add: no visible binding for global variable 'y' (R/vapour.R:5:10)",
+			out: "add: no visible binding for global variable 'y' (main.vp:3:1)",
+		},
+		{
+			in:  "Error in add(1) : R/vapour.R:3",
+			out: "Error in add(1) : main.vp:1:1",
+		},
+		// other files are left as they are
+		{
+			in:  "1: f() at myvapour.R#6",
+			out: "1: f() at myvapour.R#6",
+		},
+		// no origin
+		{
+			in:  "vapour.R#1",
+			out: "vapour.R#1",
+		},
+	}
+
+	for _, test := range tests {
+		got := Rewrite(test.in, maps)
+
+		if got != test.out {
+			t.Fatalf("expected `%v`, got `%v`", test.out, got)
+		}
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	m := testMap()
+	m.File = filepath.Join(t.TempDir(), "vapour.R")
+
+	err := m.Write()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Read(m.File)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read.Lines) != 3 || read.Lines[2] != m.Lines[2] {
+		t.Fatalf("unexpected map read: %+v", read)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vapourlang/vapour/cli"
+	"github.com/vapourlang/vapour/collate"
+	"github.com/vapourlang/vapour/compiler"
+	"github.com/vapourlang/vapour/rsource"
+	"github.com/vapourlang/vapour/sourcemap"
+)
+
+// writeSplit writes the code of each vapour file to its own
+// R file in outdir, e.g. models.vp to models.R, the outputs of
+// unchanged files are kept, the files we generated whose source
+// was deleted are removed
+func (v *vapour) writeSplit(conf cli.CLI, out compiler.Output, unchanged map[string]bool) error {
+	// vapour file: R file
+	outputs := make(map[string]string)
+	written := make(map[string]bool)
+
+	for _, f := range out.Files {
+		name := outputName(*conf.Indir, f.Path)
+
+		outputs[f.Path] = name
+		written[name] = true
+
+		path := filepath.Join(*conf.Outdir, name)
+
+		if _, err := os.Stat(path); err == nil && unchanged[f.Path] {
+			continue
+		}
+
+		err := writeOutput(f.Code, f.SourceMap, path)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err := removeStale(*conf.Outdir, written)
+
+	if err != nil {
+		return err
+	}
+
+	if !*conf.Collate {
+		return nil
+	}
+
+	deps := make(map[string][]string)
+	for file, ds := range out.Dependencies {
+		for _, d := range ds {
+			deps[outputs[file]] = append(deps[outputs[file]], outputs[d])
+		}
+	}
+
+	return writeCollate(*conf.Outdir, deps)
+}
+
+// outputs returns the R files generated from each vapour file
+func (v *vapour) outputs(conf cli.CLI) map[string][]string {
+	outputs := make(map[string][]string)
+
+	for _, f := range v.files {
+		if *conf.Split {
+			outputs[f.Path] = []string{filepath.Join(*conf.Outdir, outputName(*conf.Indir, f.Path))}
+			continue
+		}
+
+		outputs[f.Path] = []string{filepath.Join(*conf.Outdir, *conf.Outfile)}
+	}
+
+	return outputs
+}
+
+// outputName returns the name of the R file generated from the
+// vapour file, R packages have no sub-directories so these
+// prefix the name, e.g. models/user.vp gives models_user.R
+func outputName(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+
+	rel = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+
+	return strings.ReplaceAll(rel, "/", "_") + ".R"
+}
+
+// removeStale removes the R files we generated
+// that were not written by this run
+func removeStale(outdir string, written map[string]bool) error {
+	files, err := filepath.Glob(filepath.Join(outdir, "*.R"))
+
+	if err != nil {
+		return fmt.Errorf("failed to list R files: %v", err)
+	}
+
+	for _, file := range files {
+		if written[filepath.Base(file)] {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+
+		// hand-written files are never removed
+		if err != nil || !rsource.IsGenerated(string(content)) {
+			continue
+		}
+
+		err = os.Remove(file)
+
+		if err != nil {
+			return fmt.Errorf("failed to remove %v: %v", file, err)
+		}
+
+		os.Remove(sourcemap.Path(file))
+
+		fmt.Printf("removed %v, its source was deleted\n", file)
+	}
+
+	return nil
+}
+
+// writeCollate sets the Collate field of the DESCRIPTION of the
+// package to the R files of outdir, hand-written files included,
+// R requires all of them to be listed
+func writeCollate(outdir string, deps map[string][]string) error {
+	files, err := filepath.Glob(filepath.Join(outdir, "*.[Rr]"))
+
+	if err != nil {
+		return fmt.Errorf("failed to list R files: %v", err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+
+	description := filepath.Join(filepath.Dir(outdir), "DESCRIPTION")
+	err = collate.Write(description, collate.Order(names, deps))
+
+	if err != nil {
+		return fmt.Errorf("failed to write the Collate field of %v: %v", description, err)
+	}
+
+	return nil
+}
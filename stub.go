@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/r"
+)
+
+// stub writes the declarations of an installed package
+// to the project's registry, types/<pkg>.vp
+func (v *vapour) stub(pkg string) {
+	file := filepath.Join("types", pkg+".vp")
+
+	if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("%v already exists, remove it to generate a new stub", file)
+	}
+
+	exports, err := r.PackageExports(pkg)
+
+	if err != nil {
+		log.Fatalf("Failed to list the exports of %v: %v", pkg, err.Error())
+	}
+
+	// types are inferred from the docs when we have them
+	docs, err := r.PackageDocs(pkg)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read the documentation of %v: %v\n", pkg, err.Error())
+	}
+
+	topics := make(map[string]r.Doc)
+	for _, d := range docs {
+		for _, a := range d.Aliases {
+			topics[a] = d
+		}
+	}
+
+	var fns []*ast.FunctionLiteral
+	for _, name := range exports {
+		fn, err := r.GetFunctionArguments(pkg, "::", name)
+
+		if err != nil {
+			continue
+		}
+
+		fns = append(fns, stubFunction(name, fn, topics[name]))
+	}
+
+	err = os.MkdirAll("types", 0755)
+
+	if err != nil {
+		log.Fatalf("Failed to create types directory: %v", err.Error())
+	}
+
+	err = os.WriteFile(file, []byte(environment.Stub(pkg, fns).String()), 0644)
+
+	if err != nil {
+		log.Fatalf("Failed to write stub: %v", err.Error())
+	}
+
+	fmt.Printf("wrote %v declarations to %v\n", len(fns), file)
+}
+
+// stubFunction types the formals of the function from their
+// defaults or, failing that, from the documentation
+func stubFunction(name string, fn *ast.FunctionLiteral, doc r.Doc) *ast.FunctionLiteral {
+	// declared without namespace, with an empty body
+	decl := *fn
+	decl.Name = name
+	decl.Body = &ast.BlockStatement{}
+	decl.Parameters = nil
+
+	for _, p := range fn.Parameters {
+		param := *p
+
+		var types ast.Types
+		if p.Default != nil {
+			types = environment.DefaultType(p.Default.Token.Value)
+		}
+
+		if types == nil && p.Name != "..." {
+			types = environment.DescribedType(doc.Params[p.Name])
+		}
+
+		if types != nil {
+			param.Type = types
+		}
+
+		decl.Parameters = append(decl.Parameters, &param)
+	}
+
+	returns := environment.DescribedType(doc.Value)
+
+	if returns != nil {
+		decl.ReturnType = returns
+	}
+
+	return &decl
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/r"
+	"github.com/vapourlang/vapour/token"
+)
+
+func TestStubFunction(t *testing.T) {
+	param := func(name, def string) *ast.Parameter {
+		p := &ast.Parameter{Name: name, Type: ast.Types{{Name: "any"}}}
+
+		if def != "" {
+			p.Default = &ast.ExpressionStatement{Token: token.Item{Value: def}}
+		}
+
+		return p
+	}
+
+	fn := &ast.FunctionLiteral{
+		Name: "pkg::fn",
+		Parameters: []*ast.Parameter{
+			param("x", ""),
+			param("n", "1L"),
+			param("sep", `" "`),
+			param("na.rm", "FALSE"),
+			param("f", "identity"),
+			param("...", ""),
+		},
+		ReturnType: ast.Types{{Name: "any"}},
+	}
+
+	doc := r.Doc{
+		Params: map[string]string{
+			"x": "a character or numeric vector. Other objects are coerced",
+			// the default comes first
+			"n":   "a number",
+			"f":   "a function",
+			"...": "logical arguments",
+		},
+		Value: "A character vector",
+	}
+
+	decl := stubFunction("fn", fn, doc)
+
+	if decl.Name != "fn" || decl.Body == nil {
+		t.Fatalf("expected fn declared with an empty body, got %v", decl.Name)
+	}
+
+	expected := []string{"char | num", "int", "char", "bool", "any", "any"}
+
+	for i, p := range decl.Parameters {
+		var names []string
+		for _, t := range p.Type {
+			names = append(names, t.Name)
+		}
+
+		got := strings.Join(names, " | ")
+
+		if got != expected[i] {
+			t.Fatalf("parameter `%v`: expected `%v`, got `%v`", p.Name, expected[i], got)
+		}
+	}
+
+	if len(decl.ReturnType) != 1 || decl.ReturnType[0].Name != "char" {
+		t.Fatalf("expected the function to return char, got %v", decl.ReturnType)
+	}
+
+	// the function of the package is left as it is
+	if fn.Parameters[1].Type[0].Name != "any" {
+		t.Fatal("expected the parameters of the function to be copied")
+	}
+
+	// literal defaults are written as deparsed by R
+	code := environment.Stub("pkg", []*ast.FunctionLiteral{decl}).String()
+	signature := `func fn(x: char | num, n: int = 1, sep: char = " ", na.rm: bool = FALSE, f: any = NULL, ...: any): char {}`
+
+	if !strings.Contains(code, signature) {
+		t.Fatalf("expected %q in:\n%v", signature, code)
+	}
+}
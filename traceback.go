@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vapourlang/vapour/sourcemap"
+)
+
+// traceback rewrites the locations in the generated R files
+// found in R output, e.g. tracebacks or R CMD check, with
+// the locations in the vapour files, the output is read from
+// path, or from stdin when path is -
+func (v *vapour) traceback(path, outdir, outfile string) {
+	var content []byte
+	var err error
+
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		log.Fatalf("Failed to read %v: %v", path, err.Error())
+	}
+
+	maps := readSourceMaps(outdir, outfile)
+
+	if len(maps) == 0 {
+		log.Fatalf("No source map found in %v, transpile the files first", outdir)
+	}
+
+	fmt.Print(sourcemap.Rewrite(string(content), maps))
+}
+
+// readSourceMaps loads the maps of the files generated
+// in outdir and of outfile, generated with -infile
+func readSourceMaps(outdir, outfile string) []sourcemap.Map {
+	paths, _ := filepath.Glob(filepath.Join(outdir, "*"+sourcemap.Ext))
+	paths = append(paths, sourcemap.Path(outfile))
+
+	var maps []sourcemap.Map
+	for _, p := range paths {
+		m, err := sourcemap.Read(strings.TrimSuffix(p, sourcemap.Ext))
+
+		// files may not have been generated
+		if err != nil {
+			continue
+		}
+
+		maps = append(maps, m)
+	}
+
+	return maps
+}
@@ -4,111 +4,100 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/vapourlang/vapour/ast"
 	"github.com/vapourlang/vapour/cli"
+	"github.com/vapourlang/vapour/compiler"
 	"github.com/vapourlang/vapour/lexer"
-	"github.com/vapourlang/vapour/parser"
-	"github.com/vapourlang/vapour/transpiler"
-	"github.com/vapourlang/vapour/walker"
+	"github.com/vapourlang/vapour/sourcemap"
+	"github.com/vapourlang/vapour/token"
 )
 
-func (v *vapour) transpile(conf cli.CLI) bool {
+// transpile checks and writes -indir, the files that cannot be
+// read or written are returned as an error so -watch carries on
+func (v *vapour) transpile(conf cli.CLI) (bool, error) {
 	v.root = conf.Indir
 	err := v.readDir()
 
 	if err != nil {
-		log.Fatal("Failed to read vapour files")
+		return false, fmt.Errorf("failed to read vapour files: %v", err)
 	}
 
-	// lex
-	l := lexer.New(v.files)
-	l.Run()
-
-	if l.HasError() {
-		l.Errors().Print()
-		transpileFailed()
-		return false
+	// files unchanged since the last build are not checked
+	var b *build
+	opts := v.options()
+	// hand-written R files of the package
+	opts.RSource = *conf.Outdir
+	opts.Split = *conf.Split
+	opts.Skip = func(prog *ast.Program, items token.Items) map[string]bool {
+		b = v.newBuild(conf, prog, items)
+		return b.unchanged
 	}
 
-	// parse
-	p := parser.New(l)
-	prog := p.Run()
+	out := v.build(conf, v.files, opts)
 
-	if p.HasError() {
-		p.Errors().Print()
+	if out.Program == nil {
+		out.Diagnostics.Print()
 		transpileFailed()
-		return false
+		return false, nil
 	}
 
-	// walk tree
-	w := walker.New()
-	w.Walk(prog)
+	b.save(out.Statements, v.outputs(conf))
 
-	if w.HasDiagnostic() {
-		w.Errors().Print()
+	// those recorded for unchanged files fail the build too
+	out.Diagnostics = b.diagnostics(out.Diagnostics)
+
+	if len(out.Diagnostics) > 0 {
+		out.Diagnostics.Print()
 	}
 
-	if w.HasError() {
+	if out.HasError() {
 		transpileFailed()
-		return false
+		return false, nil
 	}
 
 	if *conf.Check {
-		return false
+		return false, nil
 	}
 
-	// transpile
-	trans := transpiler.New()
-	trans.Transpile(prog)
-	code := trans.GetCode()
-
 	transpileSuccessful()
 
 	if *conf.Run {
-		run(code)
-		return false
+		run(out.Code)
+		return false, nil
 	}
 
-	code = addHeader(code)
-
 	// write
-	path := *conf.Outdir + "/" + *conf.Outfile
-	f, err := os.Create(path)
-
-	if err != nil {
-		log.Fatalf("Failed to create output file: %v", err.Error())
+	if *conf.Split {
+		err = v.writeSplit(conf, out, b.unchanged)
+	} else {
+		err = writeOutput(out.Code, out.SourceMap, *conf.Outdir+"/"+*conf.Outfile)
 	}
 
-	defer f.Close()
-
-	_, err = f.WriteString(code)
-
 	if err != nil {
-		log.Fatalf("Failed to write output file: %v", err.Error())
+		return false, err
 	}
 
 	// we only generate types if it's an R package
 	if *conf.Outdir != "R" {
-		return false
+		return false, nil
 	}
 
-	// write types
-	lines := w.Env().GenerateTypes().String()
-	f, err = os.Create(*conf.Types)
+	err = ignoreSourceMaps()
 
 	if err != nil {
-		log.Fatalf("Failed to create type file: %v", err.Error())
+		return false, err
 	}
 
-	defer f.Close()
-
-	_, err = f.WriteString(lines)
+	// write types
+	err = os.WriteFile(*conf.Types, []byte(out.Types), 0644)
 
 	if err != nil {
-		log.Fatalf("Failed to write to types file: %v", err.Error())
+		return false, fmt.Errorf("failed to write types file: %v", err)
 	}
 
-	return true
+	return true, nil
 }
 
 func (v *vapour) transpileFile(conf cli.CLI) bool {
@@ -118,72 +107,89 @@ func (v *vapour) transpileFile(conf cli.CLI) bool {
 		log.Fatal("Could not read vapour file")
 	}
 
-	// lex
-	l := lexer.NewCode(*conf.Infile, string(content))
-	l.Run()
+	files := lexer.Files{{Path: *conf.Infile, Content: content}}
+	out := v.build(conf, files, v.options())
 
-	if l.HasError() {
-		l.Errors().Print()
+	if len(out.Diagnostics) > 0 {
+		out.Diagnostics.Print()
 		transpileFailed()
+	}
+
+	if out.HasError() || *conf.Check {
 		return false
 	}
 
-	// parse
-	p := parser.New(l)
-	prog := p.Run()
+	transpileSuccessful()
 
-	if p.HasError() {
-		p.Errors().Print()
-		transpileFailed()
+	if *conf.Run {
+		run(out.Code)
 		return false
 	}
 
-	// walk tree
-	w := walker.New()
-	w.Walk(prog)
-	if w.HasDiagnostic() {
-		w.Errors().Print()
-		transpileFailed()
-	}
+	err = writeOutput(out.Code, out.SourceMap, *conf.Outfile)
 
-	if w.HasError() {
-		return false
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	return true
+}
+
+// build checks the files and, unless -check, generates their code
+func (v *vapour) build(conf cli.CLI, files lexer.Files, opts compiler.Options) compiler.Output {
 	if *conf.Check {
-		return false
+		return compiler.Output{Result: compiler.Check(files, opts)}
 	}
 
-	// transpile
-	trans := transpiler.New()
-	trans.Transpile(prog)
-	code := trans.GetCode()
+	return compiler.Build(files, opts)
+}
 
-	transpileSuccessful()
+// writeOutput writes the code generated and its source map
+func writeOutput(code string, m sourcemap.Map, path string) error {
+	err := os.WriteFile(path, []byte(code), 0644)
 
-	if *conf.Run {
-		run(code)
-		return false
+	if err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
 	}
 
-	code = addHeader(code)
-
-	// write
-	f, err := os.Create(*conf.Outfile)
+	m.File = path
+	err = m.Write()
 
 	if err != nil {
-		log.Fatal("Failed to create output file")
+		return fmt.Errorf("failed to write source map: %v", err)
+	}
+
+	return nil
+}
+
+// ignoreSourceMaps adds the source maps to the .Rbuildignore
+// of the package, R CMD check rejects other files in R/
+func ignoreSourceMaps() error {
+	const pattern = `^R/.*\.R\.map$`
+
+	content, err := os.ReadFile(".Rbuildignore")
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
 	}
 
-	defer f.Close()
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
 
-	_, err = f.WriteString(code)
+	err = os.WriteFile(".Rbuildignore", append(content, pattern+"\n"...), 0644)
 
 	if err != nil {
-		log.Fatal("Failed to write to output file")
+		return fmt.Errorf("failed to write .Rbuildignore: %v", err)
 	}
 
-	return true
+	return nil
 }
 
 func transpileSuccessful() {
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vapourlang/vapour/cli"
+)
+
+func TestTranspileWarnings(t *testing.T) {
+	// the manifest of the builds is written to the cache
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	indir := t.TempDir()
+	outdir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(indir, "a.vp"), []byte(`func f(x: any): any {
+  if (x) {
+    return 1
+  }
+}
+`), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	no := false
+	outfile := "vapour.R"
+	types := filepath.Join(outdir, "types.vp")
+	conf := cli.CLI{
+		Indir:   &indir,
+		Outdir:  &outdir,
+		Outfile: &outfile,
+		Types:   &types,
+		Check:   &no,
+		Run:     &no,
+		Split:   &no,
+		Rebuild: &no,
+	}
+
+	// the second build replays the warning of the first,
+	// a failed build writes nothing
+	for i := 0; i < 2; i++ {
+		_, err := New().transpile(conf)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(filepath.Join(outdir, outfile)); !os.IsNotExist(err) {
+			t.Fatalf("build %v: expected the warning to fail the build", i+1)
+		}
+	}
+}
+
+func TestTranspileErrors(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	// errors are returned, -watch reports them and carries on
+	indir := t.TempDir()
+	outdir := filepath.Join(t.TempDir(), "missing")
+
+	err := os.WriteFile(filepath.Join(indir, "a.vp"), []byte("let x: int = 1\n"), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	no := false
+	outfile := "vapour.R"
+	types := filepath.Join(outdir, "types.vp")
+	conf := cli.CLI{
+		Indir:   &indir,
+		Outdir:  &outdir,
+		Outfile: &outfile,
+		Types:   &types,
+		Check:   &no,
+		Run:     &no,
+		Split:   &no,
+		Rebuild: &no,
+	}
+
+	_, err = New().transpile(conf)
+
+	if err == nil {
+		t.Fatal("expected an error writing to a missing directory")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	conf.Indir = &missing
+
+	_, err = New().transpile(conf)
+
+	if err == nil {
+		t.Fatal("expected an error reading a missing directory")
+	}
+}
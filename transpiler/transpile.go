@@ -5,10 +5,11 @@ import (
 
 	"github.com/vapourlang/vapour/ast"
 	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/sourcemap"
 )
 
 type Transpiler struct {
-	code []string
+	code *writer
 	env  *environment.Environment
 	opts options
 }
@@ -26,7 +27,8 @@ func New() *Transpiler {
 	env := environment.New()
 
 	return &Transpiler{
-		env: env,
+		code: &writer{},
+		env:  env,
 	}
 }
 
@@ -35,7 +37,7 @@ func (t *Transpiler) Transpile(node ast.Node) ast.Node {
 
 	// Statements
 	case *ast.Program:
-		return t.transpileProgram(node)
+		t.transpileStatements(node.Statements, true)
 
 	case *ast.ExpressionStatement:
 		if node.Expression != nil {
@@ -53,16 +55,9 @@ func (t *Transpiler) Transpile(node ast.Node) ast.Node {
 		if node.Value != nil {
 			t.transpileLetStatement(node)
 			t.Transpile(node.Value)
-			t.addNewLine()
 		}
 
-	case *ast.NewLine:
-		t.addNewLine()
-
 	case *ast.Comma:
-		if t.code[len(t.code)-1] == "\n" {
-			t.popCode()
-		}
 		t.addCode(",")
 
 	case *ast.ConstStatement:
@@ -77,11 +72,9 @@ func (t *Transpiler) Transpile(node ast.Node) ast.Node {
 		if node.Value != nil {
 			t.transpileConstStatement(node)
 			t.Transpile(node.Value)
-			t.addNewLine()
 		}
 
 	case *ast.ReturnStatement:
-		t.addNewLine()
 		t.addCode("return(")
 		t.Transpile(node.ReturnValue)
 		t.addCode(")")
@@ -112,9 +105,7 @@ func (t *Transpiler) Transpile(node ast.Node) ast.Node {
 		t.addCode(node.TokenLiteral())
 
 	case *ast.BlockStatement:
-		for _, s := range node.Statements {
-			t.Transpile(s)
-		}
+		t.transpileBlock(node)
 
 	case *ast.Attribute:
 		t.addCode(node.Value)
@@ -153,123 +144,42 @@ func (t *Transpiler) Transpile(node ast.Node) ast.Node {
 		t.addCode(")")
 
 	case *ast.For:
-		t.addCode("for(")
+		t.addCode("for (")
 		t.addCode(node.Name.Name)
 		t.addCode(" in ")
 		t.Transpile(node.Vector)
-		t.addCode(") {")
+		t.addCode(") ")
 		t.env = environment.Enclose(t.env, nil)
 		t.Transpile(node.Value)
-		t.addCode("}")
 		t.env = environment.Open(t.env)
 
 	case *ast.While:
-		t.addCode("while(")
+		t.addCode("while (")
 		t.Transpile(node.Statement)
-		t.addCode(") {")
+		t.addCode(") ")
 		t.env = environment.Enclose(t.env, nil)
 		t.Transpile(node.Value)
-		t.addCode("}")
 		t.env = environment.Open(t.env)
 
 	case *ast.InfixExpression:
-		n := t.Transpile(node.Left)
-
-		transpiled := false
-		if node.Operator == "$" {
-			switch n := n.(type) {
-			case *ast.Identifier:
-				v, exists := t.env.GetVariable(n.Value, true)
-
-				if !exists {
-					break
-				}
-
-				isStruct := false
-				for _, ty := range v.Value {
-					if ty.Name == "struct" {
-						isStruct = true
-					}
-				}
-
-				if !isStruct {
-					break
-				}
-
-				t.popCode()
-				transpiled = true
-				t.addCode("attr(" + n.Value + ", \"")
-				t.Transpile(node.Right)
-				t.addCode("\")")
-			}
-		}
-
-		if transpiled {
-			break
-		}
-
-		if t.code[len(t.code)-1] == "\n" {
-			t.popCode()
-		}
-
-		if node.Operator == "in" {
-			t.addCode(" ")
-		}
-
-		if node.Operator == ".." {
-			node.Operator = ":"
-		}
-
-		if node.Operator == "<-" {
-			node.Operator = "<<-"
-		}
-
-		if node.Operator != "+=" && node.Operator != "-=" {
-			t.addCode(node.Operator)
-		}
-
-		if node.Operator == "+=" {
-			t.addCode("=")
-			t.Transpile(node.Left)
-			t.addCode("+")
-		}
-
-		if node.Operator == "-=" {
-			t.addCode("=")
-			t.Transpile(node.Left)
-			t.addCode("-")
-		}
-
-		if node.Operator == "in" {
-			t.addCode(" ")
-		}
-
-		if node.Right != nil {
-			t.Transpile(node.Right)
-			t.addNewLine()
-		}
+		t.transpileInfixExpression(node)
 
 	case *ast.Square:
-		if t.code[len(t.code)-1] == "\n" {
-			t.popCode()
-		}
 		t.addCode(node.Token.Value)
 
 	case *ast.IfExpression:
-		t.addCode("if(isTRUE(")
+		t.addCode("if (isTRUE(")
 		t.Transpile(node.Condition)
-		t.addCode(")){")
+		t.addCode(")) ")
 		t.env = environment.Enclose(t.env, nil)
 		t.Transpile(node.Consequence)
 		t.env = environment.Open(t.env)
-		t.addCode("}")
 
 		if node.Alternative != nil {
-			t.addCode(" else {")
+			t.addCode(" else ")
 			t.env = environment.Enclose(t.env, nil)
 			t.Transpile(node.Alternative)
 			t.env = environment.Open(t.env)
-			t.addCode("}")
 		}
 
 	case *ast.FunctionLiteral:
@@ -320,21 +230,28 @@ func (t *Transpiler) Transpile(node ast.Node) ast.Node {
 			}
 
 			if i < len(node.Parameters)-1 {
-				t.addCode(",")
+				t.addCode(", ")
 			}
 		}
 
-		t.addCode(") {")
+		t.addCode(") ")
+
 		if node.Body != nil {
 			t.Transpile(node.Body)
 		}
 
+		// generics dispatch
 		if node.Body == nil {
+			t.addCode("{")
+			t.code.indent()
+			t.code.newline()
 			t.addCode("UseMethod(\"" + node.Name + "\")")
+			t.code.dedent()
+			t.code.newline()
+			t.addCode("}")
 		}
 
 		t.env = environment.Open(t.env)
-		t.addCode("}")
 
 	case *ast.DecoratorEnvironment:
 		t.env.SetEnv(
@@ -389,30 +306,166 @@ func (t *Transpiler) Transpile(node ast.Node) ast.Node {
 
 	case *ast.CallExpression:
 		t.transpileCallExpression(node)
-		t.addNewLine()
 	}
 
 	return node
 }
 
-func (t *Transpiler) transpileProgram(program *ast.Program) ast.Node {
-	var node ast.Node
+// transpileStatements writes each statement on its own lines,
+// blank lines of the source are kept, at most one, and
+// functions at the top level are separated by a blank line
+func (t *Transpiler) transpileStatements(statements []ast.Statement, top bool) {
+	var prev ast.Statement
+	newlines := 0
+
+	for i, s := range statements {
+		if _, ok := s.(*ast.NewLine); ok {
+			newlines++
+			continue
+		}
 
-	for _, statement := range program.Statements {
-		node := t.Transpile(statement)
+		// declares types, writes no code
+		if isTypeOnly(s) {
+			t.Transpile(s)
+			continue
+		}
 
-		switch n := node.(type) {
-		case *ast.ReturnStatement:
-			t.addCode("")
-			t.addCode("return(")
-			if n.ReturnValue != nil {
-				t.Transpile(n.ReturnValue)
+		switch {
+		case prev != nil && continues(prev, s):
+			// an index split in statements by the parser
+			if isComma(prev) {
+				t.addCode(" ")
 			}
-			t.addCode(")")
+		case prev != nil && (newlines > lineEnds(prev) || (top && separates(prev, statements, i))):
+			t.code.blankLine()
+		default:
+			t.code.newline()
 		}
+
+		t.code.mark(s.Item())
+		t.Transpile(s)
+
+		prev = s
+		newlines = 0
 	}
 
-	return node
+	t.code.newline()
+}
+
+func (t *Transpiler) transpileBlock(block *ast.BlockStatement) {
+	t.addCode("{")
+	t.code.newline()
+	t.code.indent()
+	t.transpileStatements(block.Statements, false)
+	t.code.dedent()
+	t.code.mark(block.Token)
+	t.addCode("}")
+}
+
+func (t *Transpiler) transpileInfixExpression(node *ast.InfixExpression) {
+	if t.transpileAttribute(node) {
+		return
+	}
+
+	if isPipe(node.Operator) {
+		t.transpilePipe(node)
+		return
+	}
+
+	t.Transpile(node.Left)
+
+	switch node.Operator {
+	case "[", "[[":
+		// closed by the square that follows
+		t.addCode(node.Operator)
+	case "$", "::", ":::":
+		t.addCode(node.Operator)
+	case "..":
+		t.addCode(":")
+	case "<-":
+		t.addCode(" <<- ")
+	case "+=":
+		t.addCode(" = ")
+		t.Transpile(node.Left)
+		t.addCode(" + ")
+	case "-=":
+		t.addCode(" = ")
+		t.Transpile(node.Left)
+		t.addCode(" - ")
+	default:
+		t.addCode(" " + node.Operator + " ")
+	}
+
+	if node.Right != nil {
+		t.Transpile(node.Right)
+	}
+}
+
+// transpileAttribute writes the attributes of structs with attr
+func (t *Transpiler) transpileAttribute(node *ast.InfixExpression) bool {
+	if node.Operator != "$" {
+		return false
+	}
+
+	n, ok := node.Left.(*ast.Identifier)
+
+	if !ok {
+		return false
+	}
+
+	v, exists := t.env.GetVariable(n.Value, true)
+
+	if !exists {
+		return false
+	}
+
+	isStruct := false
+	for _, ty := range v.Value {
+		if ty.Name == "struct" {
+			isStruct = true
+		}
+	}
+
+	if !isStruct {
+		return false
+	}
+
+	t.addCode("attr(" + n.Value + ", \"")
+	t.Transpile(node.Right)
+	t.addCode("\")")
+
+	return true
+}
+
+// transpilePipe writes each step of the pipe on its own line
+func (t *Transpiler) transpilePipe(node *ast.InfixExpression) {
+	var steps []ast.Expression
+	var operators []string
+
+	var left ast.Expression = node
+	for {
+		infix, ok := left.(*ast.InfixExpression)
+
+		if !ok || !isPipe(infix.Operator) {
+			break
+		}
+
+		steps = append([]ast.Expression{infix.Right}, steps...)
+		operators = append([]string{infix.Operator}, operators...)
+		left = infix.Left
+	}
+
+	t.Transpile(left)
+	t.code.indent()
+
+	for i, step := range steps {
+		t.addCode(" " + operators[i])
+		t.code.newline()
+		t.code.mark(step.Item())
+		t.Transpile(step)
+	}
+
+	t.code.dedent()
 }
 
 func (t *Transpiler) transpileCallExpression(node *ast.CallExpression) {
@@ -459,113 +512,95 @@ func (t *Transpiler) transpileCallExpression(node *ast.CallExpression) {
 	}
 
 	t.addCode(node.Function + "(")
-	for i, a := range node.Arguments {
-		t.Transpile(a.Value)
-		if i < len(node.Arguments)-1 {
+	t.transpileArguments(node.Arguments)
+	t.addCode(")")
+}
+
+func (t *Transpiler) transpileArguments(args []ast.Argument) {
+	for i, a := range args {
+		// closes an index in the previous argument
+		_, square := a.Value.(*ast.Square)
+
+		if i > 0 && !square {
 			t.addCode(", ")
 		}
+
+		t.Transpile(a.Value)
 	}
-	t.addCode(")")
 }
 
 func (t *Transpiler) transpileCallExpressionEnvironment(node *ast.CallExpression, typ environment.Type) {
 	t.addCode("structure(new.env(")
-	for i, a := range node.Arguments {
-		t.Transpile(a.Value)
-		if i < len(node.Arguments)-1 {
-			t.addCode(", ")
-		}
-	}
+	t.transpileArguments(node.Arguments)
 
 	cl, exists := t.env.GetClass(typ.Name)
 
 	if exists {
-		t.addCode(", class=c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
+		t.addCode(", class = c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
 		return
 	}
 
 	fct, exists := t.env.GetEnv(typ.Name)
 	if exists {
 		t.addCode(", ")
-		for _, a := range fct.Value.Arguments {
-			t.Transpile(a.Value)
-		}
+		t.transpileArguments(fct.Value.Arguments)
 	}
 	t.addCode(")")
 
-	t.addCode(", class=c(\"" + typ.Name + "\", \"environment\")")
+	t.addCode(", class = c(\"" + typ.Name + "\", \"environment\")")
 
 	t.addCode(")")
 }
 
 func (t *Transpiler) transpileCallExpressionFactor(node *ast.CallExpression, typ environment.Type) {
 	t.addCode("structure(factor(")
-	for i, a := range node.Arguments {
-		t.Transpile(a.Value)
-		if i < len(node.Arguments)-1 {
-			t.addCode(", ")
-		}
-	}
+	t.transpileArguments(node.Arguments)
 
 	cl, exists := t.env.GetClass(typ.Name)
 
 	if exists {
-		t.addCode(", class=c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
+		t.addCode(", class = c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
 		return
 	}
 
 	fct, exists := t.env.GetFactor(typ.Name)
 	if exists {
 		t.addCode(", ")
-		for _, a := range fct.Value.Arguments {
-			t.Transpile(a.Value)
-		}
+		t.transpileArguments(fct.Value.Arguments)
 	}
 	t.addCode(")")
 
-	t.addCode(", class=c(\"" + typ.Name + "\", \"factor\")")
+	t.addCode(", class = c(\"" + typ.Name + "\", \"factor\")")
 
 	t.addCode(")")
 }
 
 func (t *Transpiler) transpileCallExpressionMatrix(node *ast.CallExpression, typ environment.Type) {
 	t.addCode("structure(matrix(")
-	for i, a := range node.Arguments {
-		t.Transpile(a.Value)
-		if i < len(node.Arguments)-1 {
-			t.addCode(", ")
-		}
-	}
+	t.transpileArguments(node.Arguments)
 
 	cl, exists := t.env.GetClass(typ.Name)
 
 	if exists {
-		t.addCode(", class=c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
+		t.addCode(", class = c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
 		return
 	}
 
 	mat, exists := t.env.GetMatrix(typ.Name)
 	if exists {
 		t.addCode(", ")
-		for _, a := range mat.Value.Arguments {
-			t.Transpile(a.Value)
-		}
+		t.transpileArguments(mat.Value.Arguments)
 	}
 	t.addCode(")")
 
-	t.addCode(", class=c(\"" + typ.Name + "\", \"matrix\")")
+	t.addCode(", class = c(\"" + typ.Name + "\", \"matrix\")")
 
 	t.addCode(")")
 }
 
 func (t *Transpiler) transpileCallExpressionVector(node *ast.CallExpression, typ environment.Type) {
 	t.addCode("c(")
-	for i, a := range node.Arguments {
-		t.Transpile(a.Value)
-		if i < len(node.Arguments)-1 {
-			t.addCode(", ")
-		}
-	}
+	t.transpileArguments(node.Arguments)
 	t.addCode(")")
 }
 
@@ -584,67 +619,86 @@ func (t *Transpiler) transpileCallExpressionDataframe(node *ast.CallExpression,
 	cl, exists := t.env.GetClass(typ.Name)
 
 	if exists {
-		t.addCode(", class=c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
+		t.addCode(", class = c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
 		return
 	}
 
-	t.addCode(", class=c(\"" + typ.Name + "\", \"data.frame\")")
+	t.addCode(", class = c(\"" + typ.Name + "\", \"data.frame\")")
 
 	t.addCode(")")
 }
 
 func (t *Transpiler) transpileCallExpressionObject(node *ast.CallExpression, typ environment.Type) {
 	t.addCode("structure(new.env(")
-	for i, a := range node.Arguments {
-		t.Transpile(a.Value)
-		if i < len(node.Arguments)-1 {
-			t.addCode(", ")
-		}
-	}
+	t.transpileArguments(node.Arguments)
 	t.addCode(")")
 
 	cl, exists := t.env.GetClass(typ.Name)
 
 	if exists {
-		t.addCode(", class=c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
+		t.addCode(", class = c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
 		return
 	}
 
-	t.addCode(", class=c(\"" + typ.Name + "\", \"list\")")
+	t.addCode(", class = c(\"" + typ.Name + "\", \"list\")")
 
 	t.addCode(")")
 }
 
 func (t *Transpiler) transpileCallExpressionStruct(node *ast.CallExpression, typ environment.Type) {
 	t.addCode("structure(")
-	for i, a := range node.Arguments {
-		t.Transpile(a.Value)
-		if i < len(node.Arguments)-1 {
-			t.addCode(", ")
-		}
-	}
+	t.transpileArguments(node.Arguments)
 	cl, exists := t.env.GetClass(typ.Name)
 
 	if exists {
-		t.addCode(", class=c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
+		t.addCode(", class = c(\"" + strings.Join(cl.Value.Classes, "\", \"") + "\")")
 		return
 	}
 
-	t.addCode(", class=\"" + typ.Name + "\"")
+	t.addCode(", class = \"" + typ.Name + "\"")
 
 	t.addCode(")")
 }
 
+// Reset clears the code, the environment is kept so the
+// declarations transpiled are known to the next program
+func (t *Transpiler) Reset() {
+	t.code = &writer{}
+}
+
 func (t *Transpiler) GetCode() string {
-	return strings.Join(t.code, "")
+	return t.code.String()
 }
 
-func (t *Transpiler) addCode(code string) {
-	t.code = append(t.code, code)
+// SourceMap relates the lines of the code to the vapour
+// code they originate from, file is the generated file
+func (t *Transpiler) SourceMap(file string) sourcemap.Map {
+	m := sourcemap.Map{File: file}
+
+	for i, origin := range t.code.origins {
+		if origin.Value == "" {
+			continue
+		}
+
+		// tokens are positioned at their end
+		char := origin.Char - len(origin.Value)
+		if char < 0 {
+			char = 0
+		}
+
+		m.Lines = append(m.Lines, sourcemap.Line{
+			Line:       i + 1,
+			Source:     origin.File,
+			SourceLine: origin.Line + 1,
+			SourceChar: char + 1,
+		})
+	}
+
+	return m
 }
 
-func (t *Transpiler) popCode() {
-	t.code = t.code[:len(t.code)-1]
+func (t *Transpiler) addCode(code string) {
+	t.code.write(code)
 }
 
 func (t *Transpiler) transpileLetStatement(l *ast.LetStatement) {
@@ -655,8 +709,117 @@ func (t *Transpiler) transpileConstStatement(c *ast.ConstStatement) {
 	t.addCode(c.Name + " = ")
 }
 
-func (t *Transpiler) addNewLine() {
-	if len(t.code) > 0 && t.code[len(t.code)-1] != "\n" {
-		t.addCode("\n")
+func isPipe(operator string) bool {
+	return operator == "|>" || (strings.HasPrefix(operator, "%") && strings.HasSuffix(operator, ">%"))
+}
+
+// continues checks whether the statement is the end of
+// an index the parser split, e.g. x[1, 2] = 3
+func continues(prev, s ast.Statement) bool {
+	if isComma(prev) {
+		return true
+	}
+
+	es, ok := s.(*ast.ExpressionStatement)
+
+	if !ok {
+		return false
+	}
+
+	switch n := es.Expression.(type) {
+	case *ast.Square, *ast.Comma:
+		return true
+	case *ast.InfixExpression:
+		_, ok := n.Left.(*ast.Square)
+		return ok
+	}
+
+	return false
+}
+
+func isComma(s ast.Statement) bool {
+	es, ok := s.(*ast.ExpressionStatement)
+
+	if !ok {
+		return false
+	}
+
+	_, ok = es.Expression.(*ast.Comma)
+
+	return ok
+}
+
+// lineEnds returns the new lines that end the statement,
+// expressions and declarations consume theirs
+func lineEnds(s ast.Statement) int {
+	switch s.(type) {
+	case *ast.ExpressionStatement, *ast.LetStatement, *ast.ConstStatement, *ast.TypeStatement:
+		return 0
 	}
+
+	return 1
+}
+
+// isTypeOnly checks whether the statement produces no code
+func isTypeOnly(s ast.Statement) bool {
+	switch n := s.(type) {
+	case *ast.TypeStatement, *ast.TypeFunction:
+		return true
+	case *ast.LetStatement:
+		return n.Value == nil
+	case *ast.ConstStatement:
+		return n.Value == nil
+	case *ast.ExpressionStatement:
+		switch n.Expression.(type) {
+		case *ast.DecoratorClass, *ast.DecoratorEnvironment, *ast.DecoratorFactor, *ast.DecoratorMatrix:
+			return true
+		}
+	}
+
+	return false
+}
+
+func isFunction(s ast.Statement) bool {
+	es, ok := s.(*ast.ExpressionStatement)
+
+	if !ok {
+		return false
+	}
+
+	switch es.Expression.(type) {
+	case *ast.FunctionLiteral, *ast.DecoratorGeneric, *ast.DecoratorDefault:
+		return true
+	}
+
+	return false
+}
+
+// separates checks whether a blank line separates the statement
+// at i from the previous one, functions are set apart at the top
+// level, comments documenting them are kept attached
+func separates(prev ast.Statement, statements []ast.Statement, i int) bool {
+	if isFunction(prev) {
+		return true
+	}
+
+	if _, ok := prev.(*ast.CommentStatement); ok {
+		return false
+	}
+
+	return leadsFunction(statements, i)
+}
+
+// leadsFunction checks whether the statement is a function
+// or a comment documenting the function that follows
+func leadsFunction(statements []ast.Statement, i int) bool {
+	for ; i < len(statements); i++ {
+		switch statements[i].(type) {
+		case *ast.CommentStatement, *ast.NewLine:
+			continue
+		}
+
+		return isFunction(statements[i])
+	}
+
+	return false
 }
@@ -57,13 +57,14 @@ const anonymous: int = (): int => {
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `add = function(x = 1,y = 2) {
-total = x+y*2
-return(total)
+	expected := `add = function(x = 1, y = 2) {
+  total = x + y * 2
+  return(total)
 }
+
 # did not intend on this to work
 anonymous = function() {
-return(2)
+  return(2)
 }
 `
 
@@ -87,9 +88,10 @@ func TestPipe(t *testing.T) {
 	trans.Transpile(prog)
 
 	expected := `add = function() {
-df|>mutate(x=1
-)
-}`
+  df |>
+    mutate(x = 1)
+}
+`
 
 	trans.testOutput(t, expected)
 }
@@ -142,13 +144,14 @@ func add(): int | number {
 
 	expected := `#' @return something
 add = function() {
-# compute stuff
-x = df|>mutate(x="hello"
-, y=na
-, b=TRUE
-)|>select(x)
-return(x)
-}`
+  # compute stuff
+  x = df |>
+    mutate(x = "hello", y = na, b = TRUE) |>
+    select(x)
+
+  return(x)
+}
+`
 
 	trans.testOutput(t, expected)
 }
@@ -169,9 +172,9 @@ func TestTElipsis(t *testing.T) {
 	trans.Transpile(prog)
 
 	expected := `foo = function(...) {
-paste0(..., collapse=", "
-)
-}`
+  paste0(..., collapse = ", ")
+}
+`
 
 	trans.testOutput(t, expected)
 }
@@ -213,10 +216,10 @@ for(let i: int in 1..nrow(df)) {
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `for(i in 1:nrow(df)
-) {
-print(i)
-}`
+	expected := `for (i in 1:nrow(df)) {
+  print(i)
+}
+`
 
 	trans.testOutput(t, expected)
 }
@@ -237,9 +240,8 @@ func TestWhile(t *testing.T) {
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `while(i<10
-) {
-print(i)
+	expected := `while (i < 10) {
+  print(i)
 }
 `
 
@@ -260,8 +262,8 @@ dplyr::mutate(speed > 2) `
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `x = cars|>dplyr::mutate(speed>2
-)
+	expected := `x = cars |>
+  dplyr::mutate(speed > 2)
 `
 
 	trans.testOutput(t, expected)
@@ -295,17 +297,18 @@ func foo(n: int): null {
 	trans.Transpile(prog)
 
 	expected := `x = c(1, 2, 3)
-if(x){
-print("true")
+if (isTRUE(x)) {
+  print("true")
 } else {
-print("false")
+  print("false")
 }
+
 foo = function(n) {
-# comment
-if(n==1
-){
-print(TRUE)
-}}
+  # comment
+  if (isTRUE(n == 1)) {
+    print(TRUE)
+  }
+}
 `
 
 	trans.testOutput(t, expected)
@@ -337,11 +340,11 @@ lapply(1..10, (z: char): null => {
 	expected := `y = c(1, 2, 3)
 x = "world"
 lapply(c("hello", x), function(z) {
-print(z)
+  print(z)
 })
-lapply(1:10
-, function(z) {
-print(z)
+
+lapply(1:10, function(z) {
+  print(z)
 })
 `
 
@@ -374,10 +377,11 @@ func (p: person) setName(name: char): null {
 	trans.Transpile(prog)
 
 	expected := `add.obj = function(o, n) {
-return("hello")
+  return("hello")
 }
+
 setName.person = function(p, name) {
-p$name=2
+  p$name = 2
 }
 `
 
@@ -414,13 +418,14 @@ z$name = 2
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `x=2
-structure(list(name="hello"
-), class=c("config", "list"))
+	expected := `x = 2
+
+structure(new.env(name = "hello"), class = c("config", "list"))
+
 # should fail, does not exist
-z = structure(list(z=2
-), class=c("config", "list"))
-z$name=2
+z = structure(new.env(z = 2), class = c("config", "list"))
+
+z$name = 2
 `
 
 	trans.testOutput(t, expected)
@@ -464,19 +469,16 @@ apply_math((1, 2, 3), (x: int): int => {
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `peoples = persons(structure(list(name="John"
-), class=c("person", "list"))
-, structure(list(name="Jane"
-), class=c("person", "list"))
-)
+	expected := `peoples = persons(structure(new.env(name = "John"), class = c("person", "list")), structure(new.env(name = "Jane"), class = c("person", "list")))
+
 x = ints(1, 2, 3)
-apply_math = function(vector,cb) {
-return(cb(vector)
-)
+
+apply_math = function(vector, cb) {
+  return(cb(vector))
 }
+
 apply_math(c(1, 2, 3), function(x) {
-return(x*3
-)
+  return(x * 3)
 })
 `
 
@@ -507,12 +509,14 @@ let z: char = strsplit(zz[2], "\\|")[[1]]
 	trans.Transpile(prog)
 
 	expected := `x = c(1, 2, 3)
-x[2]=3
+x[2] = 3
+
 y = list(1, 2, 3)
-y[[1]]=1
+
+y[[1]] = 1
+
 zz = c("hello|world", "hello|again")
-z = strsplit(zz[2
-, ], "\\|")[[1]]
+z = strsplit(zz[2], "\\|")[[1]]
 `
 
 	trans.testOutput(t, expected)
@@ -538,7 +542,7 @@ userid(1, "hello")
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `structure(list(1, "hello"), class=c("userid", "list"))
+	expected := `structure(new.env(1, "hello"), class = c("userid", "list"))
 `
 
 	trans.testOutput(t, expected)
@@ -587,15 +591,13 @@ df(name = "hello", id = 1)
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `structure(42, name="xxx"
-, class="st")
-structure(list(name="hello"
-), class=c("obj", "list"))
-structure(list(name="hello"
-), class=c("hello", "world")
-structure(data.frame(name="hello"
-, id=1
-), names = c("name", "id"), class=c("df", "data.frame"))
+	expected := `structure(42, name = "xxx", class = "st")
+
+structure(new.env(name = "hello"), class = c("obj", "list"))
+
+structure(new.env(name = "hello"), class = c("hello", "world")
+
+structure(data.frame(name = "hello", id = 1), names = c("name", "id"), class = c("df", "data.frame"))
 `
 
 	trans.testOutput(t, expected)
@@ -620,10 +622,10 @@ func foo(x: int): int {
 	trans.Transpile(prog)
 
 	expected := `foo = function(x) {
-on.exit((function() {print("hello")
-})())
-return(1+1
-)
+  on.exit((function() {
+    print("hello")
+  })())
+  return(1 + 1)
 }
 `
 
@@ -670,19 +672,16 @@ func create3(): thing {
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `create = function(name,age) {
-return(structure(0, name=name
-, age=age
-, class="person")
-)
+	expected := `create = function(name, age) {
+  return(structure(0, name = name, age = age, class = "person"))
 }
+
 create2 = function() {
-return(structure(1, class="thing")
-)
+  return(structure(1, class = "thing"))
 }
+
 create3 = function() {
-return(structure(2, class=c("more", "classes", "here")
-)
+  return(structure(2, class = c("more", "classes", "here"))
 }
 `
 
@@ -715,11 +714,9 @@ thing(wheels = true)
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `structure(data.frame(name="hello"
-, age=1
-), names = c("name", "age"), class=c("df", "data.frame"))
-structure(list(wheels=TRUE
-), class=c("thing", "list"))
+	expected := `structure(data.frame(name = "hello", age = 1), names = c("name", "age"), class = c("df", "data.frame"))
+
+structure(new.env(wheels = TRUE), class = c("thing", "list"))
 `
 
 	trans.testOutput(t, expected)
@@ -749,7 +746,8 @@ lst(1, "hello")
 	trans.Transpile(prog)
 
 	expected := `c(3)
-structure(list(1, "hello"), class=c("lst", "list"))
+
+structure(new.env(1, "hello"), class = c("lst", "list"))
 `
 
 	trans.testOutput(t, expected)
@@ -806,19 +804,23 @@ type person: struct {
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `structure(list()
-, name="John"
-, class="person")
+	expected := `structure(list(), name = "John", class = "person")
+
 # should fail, attr not in type
-structure(list()
-, age=1
-, class="person")
-structure(1, class="person")
-z = structure(2, class=c("x", "y", "z")
-zzzz = structure(list(), class=c("fr", "lt")
-set_age = function(p, age) {UseMethod("set_age")}
+structure(list(), age = 1, class = "person")
+
+structure(1, class = "person")
+
+z = structure(2, class = c("x", "y", "z")
+
+zzzz = structure(new.env(), class = c("fr", "lt")
+
+set_age = function(p, age) {
+  UseMethod("set_age")
+}
+
 set_age.default = function(p, age) {
-stop("not implemented")
+  stop("not implemented")
 }
 `
 
@@ -843,7 +845,8 @@ x += 2
 	trans.Transpile(prog)
 
 	expected := `x = 10
-x=x+2
+
+x = x + 2
 `
 
 	trans.testOutput(t, expected)
@@ -869,9 +872,7 @@ mat((1, 2, 3))
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `structure(matrix(c(1, 2, 3), nrow=2
-ncol=4
-), class=c("mat", "matrix"))
+	expected := `structure(matrix(c(1, 2, 3), nrow = 2, ncol = 4), class = c("mat", "matrix"))
 `
 
 	trans.testOutput(t, expected)
@@ -897,8 +898,7 @@ fac((1, 2, 3))
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `structure(factor(c(1, 2, 3), levels=TRUE
-), class=c("fac", "factor"))
+	expected := `structure(factor(c(1, 2, 3), levels = TRUE), class = c("fac", "factor"))
 `
 
 	trans.testOutput(t, expected)
@@ -935,15 +935,16 @@ foo("hello")
 	trans := New()
 	trans.Transpile(prog)
 
-	expected := `bar(1, x=2
-, "hello")
-bar(1, x=2
-, "hello")
-foo(z=2
-)
+	expected := `bar(1, x = 2, "hello")
+
+bar(1, x = 2, "hello")
+
+foo(z = 2)
+
 foo(1, 2, 3)
-foo(z="hello"
-)
+
+foo(z = "hello")
+
 foo("hello")
 `
 
@@ -972,11 +973,59 @@ print(x) `
 	trans.Transpile(prog)
 
 	expected := `x = c(1, 2, 3)
-x[1,2]=15
-x[[3]]=15
-df$x=23
+x[1, 2] = 15
+
+x[[3]] = 15
+
+df$x = 23
+
 print(x)
 `
 
 	trans.testOutput(t, expected)
 }
+
+func TestSourceMap(t *testing.T) {
+	code := `let x: int = 1
+
+func add(a: int): int {
+  if (a > 2) {
+    stop("big")
+  }
+  return a
+}
+`
+
+	l := lexer.NewCode("main.vp", code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	trans := New()
+	trans.Transpile(prog)
+
+	m := trans.SourceMap("vapour.R")
+
+	// generated line: vapour line and character
+	expected := map[int][2]int{
+		1: {1, 1},
+		3: {3, 1},
+		4: {4, 3},
+		5: {5, 5},
+		7: {7, 3},
+	}
+
+	for line, origin := range expected {
+		o, ok := m.Lookup(line)
+
+		if !ok {
+			t.Fatalf("line %v has no origin", line)
+		}
+
+		if o.Source != "main.vp" || o.SourceLine != origin[0] || o.SourceChar != origin[1] {
+			t.Fatalf("line %v: expected main.vp:%v:%v, got %+v", line, origin[0], origin[1], o)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package transpiler
+
+import (
+	"strings"
+
+	"github.com/vapourlang/vapour/token"
+)
+
+// indentation of the generated R code
+const indent = "  "
+
+// writer builds the R code line by line
+// keeping track of the indentation
+type writer struct {
+	lines   []string
+	current strings.Builder
+	level   int
+	// a blank line separates the next line from the previous
+	blank bool
+	// vapour token the lines started next originate from
+	origin token.Item
+	// origin of each line, the last is that of the current line
+	origins []token.Item
+}
+
+// mark sets the origin of the lines started next
+func (w *writer) mark(item token.Item) {
+	w.origin = item
+}
+
+// write appends code to the current line
+func (w *writer) write(code string) {
+	if code == "" {
+		return
+	}
+
+	if w.current.Len() == 0 {
+		w.startLine(code)
+	}
+
+	w.current.WriteString(code)
+}
+
+func (w *writer) startLine(code string) {
+	// no blank lines at the start or end of blocks
+	if w.blank && len(w.lines) > 0 && !opens(w.lines[len(w.lines)-1]) && !closes(code) {
+		w.lines = append(w.lines, "")
+		w.origins = append(w.origins, token.Item{})
+	}
+
+	w.blank = false
+	w.origins = append(w.origins, w.origin)
+	w.current.WriteString(strings.Repeat(indent, w.level))
+}
+
+// newline ends the current line, if any
+func (w *writer) newline() {
+	if w.current.Len() == 0 {
+		return
+	}
+
+	w.lines = append(w.lines, strings.TrimRight(w.current.String(), " "))
+	w.current.Reset()
+}
+
+// blankLine ends the current line and places
+// a blank line before the next one
+func (w *writer) blankLine() {
+	w.newline()
+	w.blank = true
+}
+
+func (w *writer) indent() {
+	w.level++
+}
+
+func (w *writer) dedent() {
+	if w.level > 0 {
+		w.level--
+	}
+}
+
+func (w *writer) all() []string {
+	if w.current.Len() > 0 {
+		return append(w.lines, w.current.String())
+	}
+
+	return w.lines
+}
+
+func (w *writer) String() string {
+	lines := w.all()
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func opens(line string) bool {
+	return strings.HasSuffix(line, "{") || strings.HasSuffix(line, "(")
+}
+
+func closes(code string) bool {
+	return strings.HasPrefix(code, "}") || strings.HasPrefix(code, ")")
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"github.com/vapourlang/vapour/compiler"
 	"github.com/vapourlang/vapour/config"
+	"github.com/vapourlang/vapour/environment"
 	"github.com/vapourlang/vapour/lexer"
 )
 
@@ -11,6 +13,8 @@ type vapour struct {
 	root    *string
 	files   lexer.Files
 	config  *config.Config
+	// global environment kept between the runs of -watch
+	base *environment.Environment
 }
 
 func New() *vapour {
@@ -20,6 +24,11 @@ func New() *vapour {
 	}
 }
 
-func addHeader(code string) string {
-	return "# GENERATED BY VAPOUR\n# DO NOT EDIT\n" + code
+// options of the checks and builds, in watch mode the code is
+// checked against a copy of the global environment kept in memory
+func (v *vapour) options() compiler.Options {
+	return compiler.Options{
+		Config:      v.config,
+		Environment: v.base,
+	}
 }
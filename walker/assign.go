@@ -0,0 +1,226 @@
+package walker
+
+import (
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/environment"
+)
+
+// assignment records a variable assigned within a branch,
+// along with its state before the branch was walked so the
+// branch can be undone and merged with the other branches
+type assignment struct {
+	env        *environment.Environment
+	name       string
+	hasValue   bool
+	maybeValue bool
+}
+
+type assignments []assignment
+
+// state of a variable once the branch has been walked
+type assigned struct {
+	assignment
+	current environment.Variable
+}
+
+func (w *Walker) pushAssignments() {
+	w.state.assignments = append(w.state.assignments, assignments{})
+}
+
+// popAssignments undoes the assignments of the current branch
+// and returns the state the variables were left in
+func (w *Walker) popAssignments() []assigned {
+	last := len(w.state.assignments) - 1
+	frame := w.state.assignments[last]
+	w.state.assignments = w.state.assignments[:last]
+
+	var states []assigned
+	seen := make(map[assignment]bool)
+	for _, a := range frame {
+		key := assignment{env: a.env, name: a.name}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		v, _ := a.env.GetVariable(a.name, false)
+		states = append(states, assigned{assignment: a, current: v})
+
+		v.HasValue = a.hasValue
+		v.MaybeValue = a.maybeValue
+		a.env.SetVariable(a.name, v)
+	}
+
+	return states
+}
+
+// setAssigned sets the assignment state of a variable
+// and records its previous state in the current branch
+func (w *Walker) setAssigned(env *environment.Environment, name string, has, maybe bool) {
+	v, exists := env.GetVariable(name, false)
+
+	if !exists {
+		return
+	}
+
+	if len(w.state.assignments) > 0 {
+		last := len(w.state.assignments) - 1
+		w.state.assignments[last] = append(
+			w.state.assignments[last],
+			assignment{
+				env:        env,
+				name:       name,
+				hasValue:   v.HasValue,
+				maybeValue: v.MaybeValue,
+			},
+		)
+	}
+
+	v.HasValue = has
+	v.MaybeValue = maybe && !has
+	env.SetVariable(name, v)
+}
+
+// assign marks the variable as definitely assigned
+func (w *Walker) assign(name string) {
+	env, exists := w.env.GetVariableEnvironment(name)
+
+	if !exists {
+		return
+	}
+
+	w.setAssigned(env, name, true, false)
+}
+
+// mergeAssignments merges the branches of an if expression,
+// a variable is assigned after the if if every branch that
+// completes assigns it, a branch that never completes (e.g.:
+// returns) does not reach the code after the if
+func (w *Walker) mergeAssignments(consequence []assigned, consequenceEnds bool, alternative []assigned, alternativeEnds bool) {
+	type key struct {
+		env  *environment.Environment
+		name string
+	}
+
+	var keys []key
+	branches := make(map[key][2]*assigned)
+
+	add := func(states []assigned, index int) {
+		for i := range states {
+			k := key{env: states[i].env, name: states[i].name}
+			b, ok := branches[k]
+			if !ok {
+				keys = append(keys, k)
+			}
+			b[index] = &states[i]
+			branches[k] = b
+		}
+	}
+
+	add(consequence, 0)
+	add(alternative, 1)
+
+	ends := [2]bool{consequenceEnds, alternativeEnds}
+	for _, k := range keys {
+		b := branches[k]
+
+		definite := true
+		maybe := false
+		for i, s := range b {
+			has := false
+			if s != nil {
+				has = s.current.HasValue
+				maybe = maybe || s.current.HasValue || s.current.MaybeValue
+			} else {
+				// branch left the variable as it was before the if
+				other := b[1-i]
+				has = other.hasValue
+				maybe = maybe || other.hasValue || other.maybeValue
+			}
+
+			definite = definite && (has || ends[i])
+		}
+
+		w.setAssigned(k.env, k.name, definite, maybe)
+	}
+}
+
+// mergeLoopAssignments merges the body of a loop, which may
+// not run: variables it assigns might not be assigned after it
+func (w *Walker) mergeLoopAssignments(states []assigned) {
+	for _, s := range states {
+		if s.hasValue {
+			continue
+		}
+
+		maybe := s.maybeValue || s.current.HasValue || s.current.MaybeValue
+		w.setAssigned(s.env, s.name, false, maybe)
+	}
+}
+
+// isLocal checks whether the variable is declared within the
+// function being walked, variables of enclosing environments
+// may have been assigned by the time the function is called
+func (w *Walker) isLocal(name string) bool {
+	owner, exists := w.env.GetVariableEnvironment(name)
+
+	if !exists {
+		return false
+	}
+
+	for e := w.env; e != nil; e = environment.Open(e) {
+		if e == owner {
+			return true
+		}
+
+		if e == w.state.fnenv {
+			break
+		}
+	}
+
+	return false
+}
+
+// checkAssigned reports reading a local variable
+// which has not been assigned on every path
+func (w *Walker) checkAssigned(node *ast.Identifier, v environment.Variable) {
+	if v.HasValue || !w.isLocal(node.Value) {
+		return
+	}
+
+	if v.MaybeValue {
+		w.addWarnf(
+			node.Token,
+			"`%v` might be used before it is assigned",
+			node.Value,
+		)
+		return
+	}
+
+	w.addFatalf(
+		node.Token,
+		"`%v` is declared but not assigned",
+		node.Value,
+	)
+}
+
+// walkAssignee walks the left hand side of an assignment:
+// a lone identifier is written, not read, within a call
+// it is the name of an argument and walked as is
+func (w *Walker) walkAssignee(node ast.Expression) (ast.Types, ast.Node) {
+	n, ok := node.(*ast.Identifier)
+
+	if !ok || w.isIncall() {
+		return w.Walk(node)
+	}
+
+	v, exists := w.env.GetVariable(n.Value, true)
+
+	if !exists {
+		return w.Walk(node)
+	}
+
+	w.env.SetVariableUsed(n.Value)
+
+	return v.Value, n
+}
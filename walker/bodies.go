@@ -0,0 +1,89 @@
+package walker
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/diagnostics"
+)
+
+// body is the body of a top-level function, bodies are checked
+// concurrently once the top-level statements are walked
+type body struct {
+	node  *ast.FunctionLiteral
+	state state
+	// number of diagnostics reported before the body,
+	// its diagnostics are inserted there
+	at     int
+	errors diagnostics.Diagnostics
+	skips  map[int]string
+}
+
+func (w *Walker) deferBody(node *ast.FunctionLiteral) {
+	st := w.state
+	// bodies must not append to the same arrays
+	st.namespace = append([]string(nil), w.state.namespace...)
+	st.assignments = append([]assignments(nil), w.state.assignments...)
+	st.skipped = nil
+
+	w.bodies = append(w.bodies, &body{node: node, state: st, at: len(w.errors)})
+}
+
+// walkBodies checks the deferred bodies against the environment,
+// the diagnostics are in the order of a walk of one body at a time
+func (w *Walker) walkBodies() {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for _, b := range w.bodies {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(b *body) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			bw := &Walker{
+				env:       w.env,
+				config:    w.config,
+				state:     b.state,
+				unchanged: w.unchanged,
+			}
+			bw.walkNamedFunctionBody(b.node)
+			b.errors = bw.errors
+			b.skips = bw.skips
+		}(b)
+	}
+
+	wg.Wait()
+
+	if w.state.skipped == nil {
+		w.state.skipped = make(map[string]bool)
+	}
+
+	var errs diagnostics.Diagnostics
+	last := 0
+	for _, b := range w.bodies {
+		errs = append(errs, w.errors[last:b.at]...)
+		last = b.at
+
+		for i, d := range b.errors {
+			pkg, ok := b.skips[i]
+
+			// one hint per package
+			if ok && w.state.skipped[pkg] {
+				continue
+			}
+
+			if ok {
+				w.state.skipped[pkg] = true
+			}
+
+			errs = append(errs, d)
+		}
+	}
+
+	w.errors = append(errs, w.errors[last:]...)
+	w.bodies = nil
+}
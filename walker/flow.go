@@ -0,0 +1,264 @@
+package walker
+
+import (
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/token"
+)
+
+// flow describes how control leaves a statement or a block,
+// this is a structured control-flow graph: Vapour has no goto
+// so the edges of the graph follow the shape of the tree
+type flow int
+
+const (
+	// control reaches the next statement
+	flowNext flow = iota
+	// control leaves the enclosing loop (break, next)
+	flowBreak
+	// control leaves the function (return, stop)
+	flowReturn
+)
+
+// functions that never return control to the caller
+var terminatingCalls = []string{"stop", "abort", "quit", "q"}
+
+// combine merges the flows of two branches:
+// control reaches the next statement if either branch does
+func (f flow) combine(other flow) flow {
+	if f < other {
+		return f
+	}
+	return other
+}
+
+// blockFlow walks the statements of a block and returns
+// how control leaves it, along with the token where control
+// falls through (if it does), code that follows a statement
+// which never completes is reported as unreachable
+func (w *Walker) blockFlow(node *ast.BlockStatement) (flow, token.Item) {
+	return analyseBlock(node, func(s ast.Statement) {
+		w.addWarnf(
+			s.Item(),
+			"unreachable code",
+		)
+	})
+}
+
+// terminates checks whether control never leaves the block
+// through its end, e.g.: it always returns or breaks
+func terminates(node *ast.BlockStatement) bool {
+	f, _ := analyseBlock(node, nil)
+	return f != flowNext
+}
+
+// analyseBlock computes the flow of a block, unreachable is called
+// on the first statement that cannot be reached, if not nil
+func analyseBlock(node *ast.BlockStatement, unreachable func(ast.Statement)) (flow, token.Item) {
+	if node == nil {
+		return flowNext, token.Item{}
+	}
+
+	current := flowNext
+	last := node.Token
+	reported := false
+
+	for _, s := range node.Statements {
+		if isTrivia(s) {
+			continue
+		}
+
+		if current != flowNext {
+			if !reported && unreachable != nil {
+				unreachable(s)
+			}
+			reported = true
+			continue
+		}
+
+		current, last = statementFlow(s, unreachable)
+	}
+
+	return current, last
+}
+
+func statementFlow(node ast.Statement, unreachable func(ast.Statement)) (flow, token.Item) {
+	switch n := node.(type) {
+	case *ast.ReturnStatement:
+		return flowReturn, n.Token
+	case *ast.ExpressionStatement:
+		if n.Expression == nil {
+			return flowNext, n.Token
+		}
+		return expressionFlow(n.Expression, unreachable)
+	}
+
+	return flowNext, node.Item()
+}
+
+func expressionFlow(node ast.Expression, unreachable func(ast.Statement)) (flow, token.Item) {
+	switch n := node.(type) {
+	case *ast.Keyword:
+		if n.Value == "break" || n.Value == "next" {
+			return flowBreak, n.Token
+		}
+
+	case *ast.CallExpression:
+		if contains(n.Name, terminatingCalls) {
+			return flowReturn, n.Token
+		}
+
+	case *ast.IfExpression:
+		consequence, at := analyseBlock(n.Consequence, unreachable)
+
+		if n.Alternative == nil {
+			return flowNext, n.Token
+		}
+
+		alternative, altAt := analyseBlock(n.Alternative, unreachable)
+
+		if consequence == flowNext {
+			return flowNext, at
+		}
+
+		if alternative == flowNext {
+			return flowNext, altAt
+		}
+
+		return consequence.combine(alternative), n.Token
+
+	case *ast.For:
+		// the body may never run
+		analyseBlock(n.Value, unreachable)
+		return flowNext, n.Token
+
+	case *ast.While:
+		analyseBlock(n.Value, unreachable)
+
+		// while(TRUE) without break never completes
+		if isInfiniteLoop(n) && !hasBreak(n.Value) {
+			return flowReturn, n.Token
+		}
+
+		return flowNext, n.Token
+	}
+
+	return flowNext, node.Item()
+}
+
+// comments and new lines have no effect on the flow
+func isTrivia(node ast.Statement) bool {
+	switch node.(type) {
+	case *ast.CommentStatement, *ast.NewLine:
+		return true
+	}
+	return false
+}
+
+func isInfiniteLoop(node *ast.While) bool {
+	switch n := node.Statement.(type) {
+	case *ast.ExpressionStatement:
+		b, ok := n.Expression.(*ast.Boolean)
+		return ok && b.Value
+	}
+	return false
+}
+
+// hasBreak checks whether a loop body contains a break
+// that applies to this loop, nested loops and functions
+// are skipped as their break statements are their own
+func hasBreak(node *ast.BlockStatement) bool {
+	if node == nil {
+		return false
+	}
+
+	for _, s := range node.Statements {
+		es, ok := s.(*ast.ExpressionStatement)
+
+		if !ok {
+			continue
+		}
+
+		switch n := es.Expression.(type) {
+		case *ast.Keyword:
+			if n.Value == "break" {
+				return true
+			}
+		case *ast.IfExpression:
+			if hasBreak(n.Consequence) || hasBreak(n.Alternative) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkReturns reports functions that must return a value
+// but on some path reach the end of their body
+func (w *Walker) checkReturns(node *ast.FunctionLiteral, name token.Item) {
+	f, at := w.blockFlow(node.Body)
+
+	if w.state.ingeneric || !mustReturn(node.ReturnType) {
+		return
+	}
+
+	if f == flowReturn {
+		return
+	}
+
+	if !hasReturn(node.Body) {
+		if node.Name == "" {
+			w.addFatalf(
+				name,
+				"missing return",
+			)
+			return
+		}
+
+		w.addFatalf(
+			name,
+			"`%v` is missing return",
+			node.Name,
+		)
+		return
+	}
+
+	w.addFatalf(
+		at,
+		"missing return on some path",
+	)
+}
+
+// hasReturn checks whether the body returns on any path
+func hasReturn(node *ast.BlockStatement) bool {
+	if node == nil {
+		return false
+	}
+
+	for _, s := range node.Statements {
+		switch n := s.(type) {
+		case *ast.ReturnStatement:
+			return true
+		case *ast.ExpressionStatement:
+			if expressionHasReturn(n.Expression) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func expressionHasReturn(node ast.Expression) bool {
+	switch n := node.(type) {
+	case *ast.IfExpression:
+		return hasReturn(n.Consequence) || hasReturn(n.Alternative)
+	case *ast.For:
+		return hasReturn(n.Value)
+	case *ast.While:
+		return hasReturn(n.Value)
+	case *ast.CallExpression:
+		return contains(n.Name, terminatingCalls)
+	}
+	return false
+}
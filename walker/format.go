@@ -0,0 +1,235 @@
+package walker
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/vapourlang/vapour/ast"
+)
+
+// functions which take a C-style format string,
+// along with the name of the format argument and
+// the named arguments that are not formatted
+var formatCalls = map[string][]string{
+	"sprintf":  {"fmt"},
+	"gettextf": {"fmt", "domain", "trim"},
+}
+
+// types each verb accepts, `s` accepts anything
+var formatVerbs = map[byte][]string{
+	'd': {"int", "bool"},
+	'i': {"int", "bool"},
+	'o': {"int"},
+	'x': {"int"},
+	'X': {"int"},
+	'f': {"int", "num"},
+	'e': {"int", "num"},
+	'E': {"int", "num"},
+	'g': {"int", "num"},
+	'G': {"int", "num"},
+	'a': {"int", "num"},
+	'A': {"int", "num"},
+	's': nil,
+	// width or precision given as argument: %*d
+	'*': {"int"},
+}
+
+type formatVerb struct {
+	verb byte
+	// 1-based index of the argument it consumes
+	argument int
+}
+
+// parseFormat returns the verbs of the format string in order,
+// it fails on the first verb it does not understand
+func parseFormat(format string) ([]formatVerb, string, bool) {
+	var verbs []formatVerb
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		start := i
+		i++
+
+		if i < len(format) && format[i] == '%' {
+			continue
+		}
+
+		argument := 0
+		index, size := formatIndex(format[i:])
+		if size > 0 {
+			argument = index
+			i += size
+		}
+
+		for i < len(format) && strings.IndexByte("-+ #0123456789.*", format[i]) != -1 {
+			if format[i] == '*' {
+				index, size := formatIndex(format[i+1:])
+				if size > 0 {
+					verbs = append(verbs, formatVerb{verb: '*', argument: index})
+					i += size
+				} else {
+					next++
+					verbs = append(verbs, formatVerb{verb: '*', argument: next})
+				}
+			}
+			i++
+		}
+
+		if i >= len(format) {
+			return verbs, format[start:], false
+		}
+
+		_, ok := formatVerbs[format[i]]
+
+		if !ok || format[i] == '*' {
+			return verbs, format[start : i+1], false
+		}
+
+		if argument == 0 {
+			next++
+			argument = next
+		}
+
+		verbs = append(verbs, formatVerb{verb: format[i], argument: argument})
+	}
+
+	return verbs, "", true
+}
+
+// formatIndex parses the `n$` argument index
+// at the start of a verb, if present
+func formatIndex(format string) (int, int) {
+	end := strings.IndexByte(format, '$')
+
+	if end < 1 {
+		return 0, 0
+	}
+
+	index, err := strconv.Atoi(format[:end])
+
+	if err != nil || index < 1 {
+		return 0, 0
+	}
+
+	return index, end + 1
+}
+
+// checkFormat checks the arguments of calls to sprintf and
+// similar functions against the verbs of the format string
+func (w *Walker) checkFormat(node *ast.CallExpression, types []ast.Types) {
+	named, ok := formatCalls[node.Name]
+
+	if !ok {
+		return
+	}
+
+	var format *ast.StringLiteral
+	var args []ast.Types
+	for i, a := range callArguments(node) {
+		if a.Name == named[0] || (a.Name == "" && format == nil && len(args) == 0) {
+			format, _ = a.Value.(*ast.StringLiteral)
+			if format == nil {
+				// not a literal, we cannot check it
+				return
+			}
+			continue
+		}
+
+		if contains(a.Name, named) {
+			continue
+		}
+
+		args = append(args, types[i])
+	}
+
+	if format == nil {
+		return
+	}
+
+	verbs, verb, ok := parseFormat(format.Str)
+
+	if !ok {
+		w.addFatalf(
+			format.Token,
+			"unrecognised format verb `%v`",
+			verb,
+		)
+		return
+	}
+
+	used := 0
+	for _, v := range verbs {
+		if v.argument > used {
+			used = v.argument
+		}
+	}
+
+	if used > len(args) {
+		w.addFatalf(
+			node.Token,
+			"format expects %v argument(s), got %v",
+			used,
+			len(args),
+		)
+		return
+	}
+
+	if used < len(args) {
+		w.addWarnf(
+			node.Token,
+			"format uses %v argument(s), got %v",
+			used,
+			len(args),
+		)
+	}
+
+	for _, v := range verbs {
+		valid := formatVerbs[v.verb]
+
+		if valid == nil {
+			continue
+		}
+
+		t := args[v.argument-1]
+
+		if w.formatTypeValid(t, valid) {
+			continue
+		}
+
+		w.addFatalf(
+			format.Token,
+			"verb `%%%v` expects `%v`, got `%v`",
+			string(v.verb),
+			strings.Join(valid, " | "),
+			t,
+		)
+	}
+}
+
+func (w *Walker) formatTypeValid(types ast.Types, valid []string) bool {
+	for _, t := range types {
+		// e.g.: ... has no type
+		if t == nil {
+			return true
+		}
+	}
+
+	native, _ := w.getNativeTypes(types)
+
+	for _, t := range native {
+		// we don't know the type
+		if t.Name == "" || t.Name == "any" || t.Name == "na" {
+			continue
+		}
+
+		if !contains(t.Name, valid) {
+			return false
+		}
+	}
+
+	return true
+}
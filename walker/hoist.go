@@ -0,0 +1,150 @@
+package walker
+
+import (
+	"strings"
+
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/environment"
+)
+
+// hoist registers the top-level types, signatures, functions
+// and methods of the program before any body is checked,
+// the program concatenates all files so checking does not
+// depend on the order of the files or of the statements.
+// Nothing is reported here: declarations are checked when walked.
+func (w *Walker) hoist(program *ast.Program) {
+	exported := false
+	for _, s := range program.Statements {
+		switch n := s.(type) {
+		case *ast.NewLine:
+			continue
+		case *ast.CommentStatement:
+			// roxygen tag preceding the function
+			exported = exported || strings.HasPrefix(n.Value, "#' @export")
+			continue
+		case *ast.TypeStatement:
+			w.hoistType(n)
+		case *ast.TypeFunction:
+			w.hoistSignature(n)
+		case *ast.ExpressionStatement:
+			w.hoistExpression(n.Expression, false, exported)
+		}
+
+		exported = false
+	}
+}
+
+func (w *Walker) hoistExpression(node ast.Expression, anyMethod, exported bool) {
+	switch n := node.(type) {
+	case *ast.FunctionLiteral:
+		w.hoistFunction(n, anyMethod, exported)
+	case *ast.DecoratorGeneric:
+		w.hoistExpression(n.Func, true, exported)
+	case *ast.DecoratorDefault:
+		w.hoistExpression(n.Func, true, exported)
+	case *ast.DecoratorClass:
+		w.hoistType(n.Type)
+	case *ast.DecoratorEnvironment:
+		w.hoistType(n.Type)
+	case *ast.DecoratorFactor:
+		w.hoistType(n.Type)
+	case *ast.DecoratorMatrix:
+		w.hoistType(n.Type)
+	}
+}
+
+func (w *Walker) hoistType(node *ast.TypeStatement) {
+	if node == nil {
+		return
+	}
+
+	_, exists := w.env.GetType("", node.Name)
+
+	if exists {
+		return
+	}
+
+	w.env.SetType(typeFromStatement(node))
+}
+
+func (w *Walker) hoistSignature(node *ast.TypeFunction) {
+	_, exists := w.env.GetSignature(node.Name)
+
+	if exists {
+		return
+	}
+
+	w.env.SetSignature(
+		node.Name,
+		environment.Signature{
+			Token: node.Token,
+			Value: node,
+		},
+	)
+}
+
+// hoistFunction registers a named function or method,
+// methods on `any` are only valid in @generic and @default
+func (w *Walker) hoistFunction(node *ast.FunctionLiteral, anyMethod, exported bool) {
+	if node.Name == "" {
+		return
+	}
+
+	if node.Method == nil {
+		_, exists := w.env.GetFunction(node.Name, false)
+
+		if exists {
+			return
+		}
+
+		w.env.SetFunction(
+			node.Name,
+			environment.Function{
+				Token:    node.Token,
+				Value:    node,
+				Exported: exported,
+			},
+		)
+		return
+	}
+
+	if node.Method.Name == "any" && !anyMethod {
+		return
+	}
+
+	w.env.AddMethod(
+		node.Name,
+		environment.Method{
+			Token:    node.Token,
+			Value:    node,
+			Exported: exported,
+		},
+	)
+}
+
+// isHoisted checks whether the method was registered by hoist
+func (w *Walker) isHoisted(node *ast.FunctionLiteral) bool {
+	methods, exists := w.env.GetMethods(node.Name)
+
+	if !exists {
+		return false
+	}
+
+	for _, m := range methods {
+		if m.Value == node {
+			return true
+		}
+	}
+
+	return false
+}
+
+func typeFromStatement(node *ast.TypeStatement) environment.Type {
+	return environment.Type{
+		Token:      node.Token,
+		Type:       node.Type,
+		Attributes: node.Attributes,
+		Object:     node.Object,
+		Name:       node.Name,
+	}
+}
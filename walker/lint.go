@@ -0,0 +1,106 @@
+package walker
+
+import (
+	"sort"
+
+	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/token"
+)
+
+// checkShadow reports a declaration that hides a variable
+// of an enclosing scope of the same function
+func (w *Walker) checkShadow(tok token.Item, name string) {
+	if !w.config.Lint.Shadow {
+		return
+	}
+
+	_, exists := w.env.GetVariable(name, false)
+
+	if exists {
+		return
+	}
+
+	_, exists = w.env.GetVariable(name, true)
+
+	if !exists || !w.isLocal(name) {
+		return
+	}
+
+	w.addInfof(
+		tok,
+		"`%v` shadows a variable of an enclosing scope",
+		name,
+	)
+}
+
+func (w *Walker) warnUnusedParameter(v environment.Variable) {
+	if !w.config.Lint.UnusedParameter {
+		return
+	}
+
+	w.addInfof(
+		v.Token,
+		"parameter `%v` is never used",
+		v.Token.Value,
+	)
+}
+
+// warnUnusedFunctions reports top-level functions
+// that are neither called nor exported
+func (w *Walker) warnUnusedFunctions() {
+	if !w.config.Lint.UnusedFunction {
+		return
+	}
+
+	fns := w.env.Functions()
+
+	for _, k := range byPosition(fns, func(fn environment.Function) token.Item { return fn.Token }) {
+		fn := fns[k]
+
+		// external functions
+		if fn.Package != "" || fn.Value == nil {
+			continue
+		}
+
+		if fn.Used || fn.Exported {
+			continue
+		}
+
+		w.addInfof(
+			fn.Value.NameToken,
+			"function `%v` is never used",
+			k,
+		)
+	}
+}
+
+// byPosition returns the keys of the map in the order in which
+// their tokens appear in the code, so diagnostics are reported
+// in the same order on every run
+func byPosition[T any](m map[string]T, tok func(T) token.Item) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := tok(m[keys[i]]), tok(m[keys[j]])
+
+		if a.File != b.File {
+			return a.File < b.File
+		}
+
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		if a.Char != b.Char {
+			return a.Char < b.Char
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
@@ -0,0 +1,80 @@
+package walker
+
+import (
+	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/diagnostics"
+	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/token"
+)
+
+// Skip leaves the statements of the files unchecked, e.g. files
+// unchanged since the last build: their declarations are still
+// registered and the functions they call are marked as used,
+// items are the tokens of the program
+func (w *Walker) Skip(files map[string]bool, items token.Items) {
+	w.unchanged = files
+
+	for _, it := range items {
+		if files[it.File] && it.Class == token.ItemIdent {
+			w.unchangedItems = append(w.unchangedItems, it)
+		}
+	}
+}
+
+// StatementErrors returns the diagnostics of the statements,
+// those on the whole program, e.g. unused functions, aside:
+// they are the diagnostics a file keeps while it does not change
+func (w *Walker) StatementErrors() diagnostics.Diagnostics {
+	return w.errors[:w.walked]
+}
+
+// declare registers the variables of a statement that is not
+// walked, functions and types were hoisted, type decorators are
+// walked as they declare the classes the types construct.
+// Variables are assigned: the file passed the checks, its
+// assignments, e.g. x = 1 after let x: int, are not walked
+func (w *Walker) declare(s ast.Statement) {
+	switch n := s.(type) {
+	case *ast.LetStatement:
+		w.env.SetVariable(
+			n.Name,
+			environment.Variable{
+				Token:    n.Token,
+				Value:    n.Type,
+				Name:     n.Name,
+				HasValue: true,
+			},
+		)
+	case *ast.ConstStatement:
+		w.env.SetVariable(
+			n.Name,
+			environment.Variable{
+				Token:    n.Token,
+				Value:    n.Type,
+				Name:     n.Name,
+				IsConst:  true,
+				HasValue: true,
+			},
+		)
+	case *ast.TypeStatement, *ast.TypeFunction:
+		w.Walk(s)
+	case *ast.ExpressionStatement:
+		switch n.Expression.(type) {
+		case *ast.DecoratorClass, *ast.DecoratorEnvironment, *ast.DecoratorFactor, *ast.DecoratorMatrix:
+			w.Walk(s)
+		}
+	}
+}
+
+// useUnchanged marks the functions and variables
+// named in the files not walked as used
+func (w *Walker) useUnchanged() {
+	for _, it := range w.unchangedItems {
+		w.env.SetFunctionUsed(it.Value)
+
+		// would otherwise declare the variable
+		if _, ok := w.env.GetVariable(it.Value, false); ok {
+			w.env.SetVariableUsed(it.Value)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/vapourlang/vapour/ast"
 	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/token"
 )
 
 type Function struct {
@@ -73,10 +74,7 @@ func acceptAny(types ast.Types) bool {
 
 func (w *Walker) typesValid(valid, actual ast.Types) bool {
 	validNative, _ := w.getNativeTypes(valid)
-	actualNative, _ := w.getNativeTypes(actual)
-
 	validNative = append(validNative, valid...)
-	actualNative = append(actualNative, actual...)
 
 	// we don't have the type
 	if len(validNative) == 0 {
@@ -87,6 +85,9 @@ func (w *Walker) typesValid(valid, actual ast.Types) bool {
 		return true
 	}
 
+	actualNative, _ := w.getNativeTypes(actual)
+	actualNative = append(actualNative, actual...)
+
 	for _, l := range actualNative {
 		if w.typeValid(l, validNative) {
 			continue
@@ -272,8 +273,7 @@ func (w *Walker) checkIdentifier(node *ast.Identifier) {
 	_, exists = w.env.GetFunction(node.Value, true)
 
 	if exists {
-		// we currently don't set/check used functions
-		// they may be exported from a package (and thus not used)
+		w.env.SetFunctionUsed(node.Value)
 		return
 	}
 
@@ -348,7 +348,11 @@ func (w *Walker) attributeMatch(arg ast.Argument, inc ast.Types, t environment.T
 }
 
 func (w *Walker) warnUnusedTypes() {
-	for k, v := range w.env.Types() {
+	types := w.env.Types()
+
+	for _, k := range byPosition(types, func(t environment.Type) token.Item { return t.Token }) {
+		v := types[k]
+
 		if v.Used {
 			continue
 		}
@@ -361,10 +365,20 @@ func (w *Walker) warnUnusedTypes() {
 }
 
 func (w *Walker) warnUnusedVariables() {
-	for k, v := range w.env.Variables() {
+	vars := w.env.Variables()
+
+	for _, k := range byPosition(vars, func(v environment.Variable) token.Item { return v.Token }) {
+		v := vars[k]
+
 		if v.Used {
 			continue
 		}
+
+		if v.IsParam {
+			w.warnUnusedParameter(v)
+			continue
+		}
+
 		w.addInfof(
 			v.Token,
 			"`%v` is never used",
@@ -1,16 +1,32 @@
 package walker
 
 import (
+	"errors"
+
 	"github.com/vapourlang/vapour/ast"
+	"github.com/vapourlang/vapour/config"
 	"github.com/vapourlang/vapour/diagnostics"
 	"github.com/vapourlang/vapour/environment"
 	"github.com/vapourlang/vapour/r"
+	"github.com/vapourlang/vapour/token"
 )
 
 type Walker struct {
 	errors diagnostics.Diagnostics
 	env    *environment.Environment
 	state  state
+	config *config.Config
+	// files whose statements are not walked, see Skip
+	unchanged      map[string]bool
+	unchangedItems token.Items
+	// number of diagnostics reported on statements
+	walked int
+	// whether the bodies of top-level functions
+	// are deferred to be checked concurrently
+	concurrent bool
+	bodies     []*body
+	// packages of the hints of skipPackage, by index in errors
+	skips map[int]string
 }
 
 type state struct {
@@ -18,14 +34,36 @@ type state struct {
 	indefault bool
 	namespace []string
 	incall    int
+	// environment of the function being walked
+	fnenv *environment.Environment
+	// assignments made in the branches being walked
+	assignments []assignments
+	// packages whose checks were skipped in offline mode
+	skipped map[string]bool
 }
 
 func New() *Walker {
+	return NewWithEnvironment(environment.NewGlobalEnvironment())
+}
+
+// NewWithEnvironment returns a walker checking the code against
+// the environment, e.g. a copy of a global environment built once
+func NewWithEnvironment(env *environment.Environment) *Walker {
 	return &Walker{
-		env: environment.NewGlobalEnvironment(),
+		env:    env,
+		config: config.Default(),
 	}
 }
 
+// Configure sets which optional diagnostics are reported
+func (w *Walker) Configure(conf *config.Config) {
+	if conf == nil || conf.Lint == nil {
+		return
+	}
+
+	w.config = conf
+}
+
 func (w *Walker) Run(node ast.Node) {
 	w.Walk(node)
 	w.warnUnusedTypes()
@@ -126,27 +164,13 @@ func (w *Walker) Walk(node ast.Node) (ast.Types, ast.Node) {
 		w.walkFor(node)
 
 	case *ast.While:
-		w.Walk(node.Statement)
-		w.env = environment.Enclose(w.env, nil)
-		t, n := w.Walk(node.Value)
-		w.env = environment.Open(w.env)
-		return t, n
+		return w.walkWhile(node)
 
 	case *ast.InfixExpression:
 		return w.walkInfixExpression(node)
 
 	case *ast.IfExpression:
-		w.Walk(node.Condition)
-
-		w.env = environment.Enclose(w.env, nil)
-		w.Walk(node.Consequence)
-		w.env = environment.Open(w.env)
-
-		if node.Alternative != nil {
-			w.env = environment.Enclose(w.env, nil)
-			w.Walk(node.Alternative)
-			w.env = environment.Open(w.env)
-		}
+		w.walkIfExpression(node)
 
 	case *ast.FunctionLiteral:
 		w.walkFunctionLiteral(node)
@@ -162,7 +186,15 @@ func (w *Walker) walkProgram(program *ast.Program) (ast.Types, ast.Node) {
 	var node ast.Node
 	var types ast.Types
 
+	w.hoist(program)
+
+	w.concurrent = true
 	for _, statement := range program.Statements {
+		if w.unchanged[statement.Item().File] {
+			w.declare(statement)
+			continue
+		}
+
 		types, node = w.Walk(statement)
 
 		switch n := node.(type) {
@@ -173,6 +205,13 @@ func (w *Walker) walkProgram(program *ast.Program) (ast.Types, ast.Node) {
 		}
 	}
 
+	w.concurrent = false
+	w.walkBodies()
+
+	w.useUnchanged()
+	w.walked = len(w.errors)
+	w.warnUnusedFunctions()
+
 	return types, node
 }
 
@@ -185,10 +224,18 @@ func (w *Walker) walkCallExpression(node *ast.CallExpression) (ast.Types, ast.No
 
 	// we skip where there is no package, it's currently an indicator of external fn
 	// we skip if it has elipsis, we can't check that
+	if exists {
+		w.env.SetFunctionUsed(node.Name)
+	}
+
 	if exists && fn.Package == "" {
 		return w.walkKnownCallExpression(node, fn.Value)
 	}
 
+	if exists && fn.HasSignature() {
+		return w.walkDeclaredCallExpression(node, fn.Value)
+	}
+
 	me, exists := w.env.GetMethods(node.Name)
 
 	if exists && fn.Package == "" {
@@ -206,6 +253,7 @@ func (w *Walker) walkCallExpression(node *ast.CallExpression) (ast.Types, ast.No
 	}
 
 	if contains(node.Name, []string{"library", "require"}) {
+		w.env.AttachPackage(packageArgument(node))
 		w.addHintf(
 			node.Token,
 			"use namespace::foo instead of library() or require()",
@@ -213,14 +261,44 @@ func (w *Walker) walkCallExpression(node *ast.CallExpression) (ast.Types, ast.No
 		return ast.Types{}, node
 	}
 
+	if node.Name == "requireNamespace" {
+		w.env.LoadPackageTypes(packageArgument(node))
+	}
+
+	var types []ast.Types
 	for _, v := range node.Arguments {
-		w.Walk(v.Value)
+		t, _ := w.Walk(v.Value)
 		w.checkIfIdentifier(v.Value)
+
+		if isClosingSquare(v) {
+			continue
+		}
+
+		types = append(types, t)
 	}
 
+	w.checkFormat(node, types)
+
 	return ast.Types{}, node
 }
 
+// walkDeclaredCallExpression checks calls to external
+// functions from the declaration library
+func (w *Walker) walkDeclaredCallExpression(node *ast.CallExpression, fn *ast.FunctionLiteral) (ast.Types, ast.Node) {
+	t, n := w.walkKnownCallExpression(node, fn)
+
+	for _, v := range node.Arguments {
+		w.checkIfIdentifier(v.Value)
+	}
+
+	// the result depends on the arguments
+	if acceptAny(t) {
+		return ast.Types{}, n
+	}
+
+	return t, n
+}
+
 func (w *Walker) walkCallExpressionMissing(node *ast.CallExpression) (ast.Types, ast.Node) {
 	for _, v := range node.Arguments {
 		w.callIfIdentifier(v.Value, func(node *ast.Identifier) {
@@ -542,8 +620,10 @@ func (w *Walker) walkKnownCallMethodExpression(node *ast.CallExpression, ms envi
 func (w *Walker) walkKnownCallExpression(node *ast.CallExpression, fn *ast.FunctionLiteral) (ast.Types, ast.Node) {
 	dots := hasElipsis(fn.Parameters)
 
-	for argumentIndex, argument := range node.Arguments {
+	var types []ast.Types
+	for argumentIndex, argument := range callArguments(node) {
 		argumentType, _ := w.Walk(argument.Value)
+		types = append(types, argumentType)
 
 		param, ok := getFunctionParameter(fn.Parameters, argument.Name, argumentIndex)
 
@@ -644,9 +724,56 @@ func (w *Walker) walkKnownCallExpression(node *ast.CallExpression, fn *ast.Funct
 		}
 	}
 
+	w.checkFormat(node, types)
+
 	return fn.ReturnType, node
 }
 
+// packageArgument returns the package passed to
+// library(), require() or requireNamespace()
+func packageArgument(node *ast.CallExpression) string {
+	for _, a := range callArguments(node) {
+		value := a.Value
+
+		if a.Name != "" && a.Name != "package" {
+			continue
+		}
+
+		if infix, ok := value.(*ast.InfixExpression); ok && a.Name != "" {
+			value = infix.Right
+		}
+
+		switch n := value.(type) {
+		case *ast.Identifier:
+			return n.Value
+		case *ast.StringLiteral:
+			return n.Str
+		}
+
+		return ""
+	}
+
+	return ""
+}
+
+// callArguments returns the arguments of the call, the parser
+// keeps the closing bracket of x[i] as an argument of its own
+func callArguments(node *ast.CallExpression) []ast.Argument {
+	var args []ast.Argument
+	for _, a := range node.Arguments {
+		if isClosingSquare(a) {
+			continue
+		}
+		args = append(args, a)
+	}
+	return args
+}
+
+func isClosingSquare(arg ast.Argument) bool {
+	_, ok := arg.Value.(*ast.Square)
+	return ok
+}
+
 func hasElipsis(params []*ast.Parameter) bool {
 	for _, p := range params {
 		if p.Name == "..." {
@@ -662,6 +789,12 @@ func getFunctionParameter(params []*ast.Parameter, name string, index int) (*ast
 			return p, true
 		}
 
+		// parameters after ... can only be matched by name,
+		// the caller checks the argument against ...
+		if name == "" && p.Name == "..." && i <= index {
+			return &ast.Parameter{}, false
+		}
+
 		if name == "" && i == index {
 			return p, true
 		}
@@ -733,10 +866,52 @@ func (w *Walker) walkInfixExpression(node *ast.InfixExpression) ([]*ast.Type, as
 	}
 }
 
+func (w *Walker) walkIfExpression(node *ast.IfExpression) {
+	w.Walk(node.Condition)
+
+	w.pushAssignments()
+	w.env = environment.Enclose(w.env, nil)
+	w.Walk(node.Consequence)
+	w.env = environment.Open(w.env)
+	consequence := w.popAssignments()
+
+	var alternative []assigned
+	if node.Alternative != nil {
+		w.pushAssignments()
+		w.env = environment.Enclose(w.env, nil)
+		w.Walk(node.Alternative)
+		w.env = environment.Open(w.env)
+		alternative = w.popAssignments()
+	}
+
+	w.mergeAssignments(
+		consequence,
+		terminates(node.Consequence),
+		alternative,
+		node.Alternative != nil && terminates(node.Alternative),
+	)
+}
+
+func (w *Walker) walkWhile(node *ast.While) (ast.Types, ast.Node) {
+	w.Walk(node.Statement)
+
+	w.pushAssignments()
+	w.env = environment.Enclose(w.env, nil)
+	t, n := w.Walk(node.Value)
+	w.env = environment.Open(w.env)
+	w.mergeLoopAssignments(w.popAssignments())
+
+	return t, n
+}
+
 func (w *Walker) walkFor(node *ast.For) {
 	w.env = environment.Enclose(w.env, nil)
 	w.Walk(node.Name)
 
+	if node.Name != nil {
+		w.assign(node.Name.Name)
+	}
+
 	vectorType, vectorNode := w.Walk(node.Vector)
 	ok := w.validIteratorTypes(vectorType)
 
@@ -748,7 +923,9 @@ func (w *Walker) walkFor(node *ast.For) {
 		)
 	}
 
+	w.pushAssignments()
 	w.walkBlockStatement(node.Value)
+	w.mergeLoopAssignments(w.popAssignments())
 	w.env = environment.Open(w.env)
 }
 
@@ -969,7 +1146,13 @@ func (w *Walker) walkInfixExpressionNS(node *ast.InfixExpression, operator strin
 
 	exists, err := r.PackageIsInstalled(ln.Item().Value)
 
-	if err != nil {
+	offline := errors.Is(err, r.ErrOffline)
+
+	if offline {
+		w.skipPackage(ln.Item())
+	}
+
+	if err != nil && !offline {
 		w.addInfof(
 			ln.Item(),
 			"error checking if package `%v` is installed",
@@ -977,7 +1160,7 @@ func (w *Walker) walkInfixExpressionNS(node *ast.InfixExpression, operator strin
 		)
 	}
 
-	if !exists {
+	if !exists && !offline {
 		w.addHintf(
 			ln.Item(),
 			"package `%v` is not installed",
@@ -994,7 +1177,12 @@ func (w *Walker) walkInfixExpressionNS(node *ast.InfixExpression, operator strin
 		)
 	}
 
-	rt, rn := w.Walk(node.Right)
+	rt, rn := w.walkNamespacedRight(ln.Item().Value, operator, node.Right, exists)
+
+	// R is not asked about the functions of missing packages
+	if offline || !exists {
+		return rt, rn
+	}
 
 	switch n := rn.(type) {
 	case *ast.CallExpression:
@@ -1031,6 +1219,73 @@ func (w *Walker) walkInfixExpressionNS(node *ast.InfixExpression, operator strin
 	return rt, rn
 }
 
+// skipPackage tells the user, once per package, that
+// the checks which need R were not run
+func (w *Walker) skipPackage(tok token.Item) {
+	if w.state.skipped == nil {
+		w.state.skipped = make(map[string]bool)
+	}
+
+	if w.state.skipped[tok.Value] {
+		return
+	}
+
+	w.state.skipped[tok.Value] = true
+
+	if w.skips == nil {
+		w.skips = make(map[int]string)
+	}
+
+	w.skips[len(w.errors)] = tok.Value
+	w.addHintf(
+		tok,
+		"package `%v` not checked: R is not available",
+		tok.Value,
+	)
+}
+
+// walkNamespacedRight walks the right hand side of pkg::fn,
+// calls are checked against the formals of the function,
+// fetched from R only if the package is installed
+func (w *Walker) walkNamespacedRight(pkg, operator string, node ast.Expression, installed bool) (ast.Types, ast.Node) {
+	n, ok := node.(*ast.CallExpression)
+
+	if !ok {
+		return w.Walk(node)
+	}
+
+	// we could be calling a type from another package
+	_, isType := w.env.GetType(pkg, n.Function)
+
+	if isType {
+		return w.Walk(node)
+	}
+
+	// typed declarations shipped by the package come first
+	declared, ok := w.env.GetPackageFunction(pkg, n.Function)
+	fn := declared.Value
+
+	if !ok || !declared.HasSignature() {
+		if !installed {
+			return w.Walk(node)
+		}
+
+		var err error
+		fn, err = r.GetFunctionArguments(pkg, operator, n.Function)
+
+		if err != nil {
+			return w.Walk(node)
+		}
+	}
+
+	w.incCallState()
+	defer func() {
+		w.decCallState()
+	}()
+
+	return w.walkDeclaredCallExpression(n, fn)
+}
+
 func (w *Walker) walkInfixExpressionEqualMath(node *ast.InfixExpression) (ast.Types, ast.Node) {
 	lt, ln := w.Walk(node.Left)
 
@@ -1085,7 +1340,7 @@ func (w *Walker) walkInfixExpressionEqualMath(node *ast.InfixExpression) (ast.Ty
 }
 
 func (w *Walker) walkInfixExpressionEqual(node *ast.InfixExpression) (ast.Types, ast.Node) {
-	lt, ln := w.Walk(node.Left)
+	lt, ln := w.walkAssignee(node.Left)
 
 	if !w.isIncall() {
 		w.checkIfIdentifier(ln)
@@ -1134,6 +1389,12 @@ func (w *Walker) walkInfixExpressionEqual(node *ast.InfixExpression) (ast.Types,
 
 	w.checkIfIdentifier(rn)
 
+	if !w.isIncall() {
+		w.callIfIdentifier(node.Left, func(n *ast.Identifier) {
+			w.assign(n.Value)
+		})
+	}
+
 	return rt, rn
 }
 
@@ -1174,6 +1435,8 @@ func (w *Walker) walkLetStatement(node *ast.LetStatement) (ast.Types, ast.Node)
 		return w.Walk(node.Value)
 	}
 
+	w.checkShadow(node.Token, node.Name)
+
 	w.env.SetVariable(
 		node.Name,
 		environment.Variable{
@@ -1194,6 +1457,11 @@ func (w *Walker) walkLetStatement(node *ast.LetStatement) (ast.Types, ast.Node)
 	}
 
 	rt, rn := w.Walk(node.Value)
+
+	if node.Value != nil {
+		w.assign(node.Name)
+	}
+
 	missingType, ok := w.typesExist(rt)
 
 	if !ok {
@@ -1240,13 +1508,16 @@ func (w *Walker) walkConstStatement(node *ast.ConstStatement) (ast.Types, ast.No
 		)
 	}
 
+	w.checkShadow(node.Token, node.Name)
+
 	w.env.SetVariable(
 		node.Name,
 		environment.Variable{
-			Token:   node.Token,
-			Value:   node.Type,
-			Name:    node.Name,
-			IsConst: true,
+			Token:    node.Token,
+			Value:    node.Type,
+			Name:     node.Name,
+			IsConst:  true,
+			HasValue: node.Value != nil,
 		},
 	)
 
@@ -1486,9 +1757,10 @@ func (w *Walker) walkDecoratorClass(node *ast.DecoratorClass) (ast.Types, ast.No
 }
 
 func (w *Walker) walkTypeFunction(node *ast.TypeFunction) {
-	_, exists := w.env.GetSignature(node.Name)
+	s, exists := w.env.GetSignature(node.Name)
 
-	if exists {
+	// hoisted signatures are already in the environment
+	if exists && s.Value != node {
 		w.addFatalf(
 			node.Token,
 			"signature `%v` already defined",
@@ -1506,9 +1778,10 @@ func (w *Walker) walkTypeFunction(node *ast.TypeFunction) {
 }
 
 func (w *Walker) walkTypeStatement(node *ast.TypeStatement) {
-	_, exists := w.env.GetType("", node.Name)
+	t, exists := w.env.GetType("", node.Name)
 
-	if exists {
+	// hoisted types are already in the environment
+	if exists && t.Token != node.Token {
 		w.addFatalf(
 			node.Token,
 			"type `%v` already defined",
@@ -1556,15 +1829,7 @@ func (w *Walker) walkTypeStatement(node *ast.TypeStatement) {
 		params[a.Name] = true
 	}
 
-	w.env.SetType(
-		environment.Type{
-			Token:      node.Token,
-			Type:       node.Type,
-			Attributes: node.Attributes,
-			Object:     node.Object,
-			Name:       node.Name,
-		},
-	)
+	w.env.SetType(typeFromStatement(node))
 }
 
 func (w *Walker) walkIdentifier(node *ast.Identifier) (ast.Types, ast.Node) {
@@ -1580,6 +1845,8 @@ func (w *Walker) walkIdentifier(node *ast.Identifier) (ast.Types, ast.Node) {
 			)
 		}
 
+		w.checkAssigned(node, v)
+
 		return v.Value, node
 	}
 
@@ -1622,10 +1889,11 @@ func (w *Walker) walkFunctionLiteral(node *ast.FunctionLiteral) {
 }
 
 func (w *Walker) walkNamedFunctionLiteral(node *ast.FunctionLiteral) {
-	_, exists := w.env.GetFunction(node.Name, false)
+	fn, exists := w.env.GetFunction(node.Name, false)
 
 	// we don't flag if it's a method
-	if exists && node.Method == nil {
+	// or if the function was hoisted
+	if exists && node.Method == nil && fn.Value != node {
 		w.addFatalf(
 			node.NameToken,
 			"function `%v` is already defined",
@@ -1654,7 +1922,7 @@ func (w *Walker) walkNamedFunctionLiteral(node *ast.FunctionLiteral) {
 		return
 	}
 
-	if node.Method == nil {
+	if node.Method == nil && fn.Value != node {
 		w.env.SetFunction(node.Name, environment.Function{Token: node.Token, Value: node})
 	}
 
@@ -1662,6 +1930,11 @@ func (w *Walker) walkNamedFunctionLiteral(node *ast.FunctionLiteral) {
 
 	if node.Method != nil && exists && !w.state.indefault {
 		for _, m := range methods {
+			// only flag methods declared before this one
+			if m.Value == node {
+				break
+			}
+
 			if m.Value.Method.Name != node.Method.Name {
 				continue
 			}
@@ -1675,12 +1948,30 @@ func (w *Walker) walkNamedFunctionLiteral(node *ast.FunctionLiteral) {
 		}
 	}
 
-	if node.Method != nil {
+	if node.Method != nil && !w.isHoisted(node) {
 		w.env.AddMethod(node.Name, environment.Method{Token: node.Token, Value: node})
 	}
 
+	// top-level functions only depend on the declarations
+	if w.concurrent && w.state.fnenv == nil {
+		w.deferBody(node)
+		return
+	}
+
+	w.walkNamedFunctionBody(node)
+}
+
+// walkNamedFunctionBody checks the parameters and the body
+// of the function in an environment enclosing the current one
+func (w *Walker) walkNamedFunctionBody(node *ast.FunctionLiteral) {
 	w.env = environment.Enclose(w.env, node.ReturnType)
 
+	// assignments within the body do not affect
+	// the enclosing environment until the function is called
+	fnenv := w.state.fnenv
+	w.state.fnenv = w.env
+	w.pushAssignments()
+
 	// we set the parameters in the environment
 	// and check that we don't have duplicates
 	paramsMap := make(map[string]bool)
@@ -1689,10 +1980,11 @@ func (w *Walker) walkNamedFunctionLiteral(node *ast.FunctionLiteral) {
 		w.env.SetVariable(
 			node.MethodVariable,
 			environment.Variable{
-				Token: node.Token,
-				Value: ast.Types{node.Method},
-				Name:  node.MethodVariable,
-				Used:  true,
+				Token:    node.Token,
+				Value:    ast.Types{node.Method},
+				Name:     node.MethodVariable,
+				Used:     true,
+				HasValue: true,
 			},
 		)
 	}
@@ -1719,11 +2011,13 @@ func (w *Walker) walkNamedFunctionLiteral(node *ast.FunctionLiteral) {
 		w.env.SetVariable(
 			p.Token.Value,
 			environment.Variable{
-				Token:   p.Token,
-				Value:   p.Type,
-				CanMiss: p.Default == nil || p.Name == "...",
-				Name:    p.Name,
-				Used:    used,
+				Token:    p.Token,
+				Value:    p.Type,
+				CanMiss:  p.Default == nil || p.Name == "...",
+				Name:     p.Name,
+				Used:     used,
+				HasValue: true,
+				IsParam:  true,
 			},
 		)
 
@@ -1740,28 +2034,17 @@ func (w *Walker) walkNamedFunctionLiteral(node *ast.FunctionLiteral) {
 		paramsMap[p.Token.Value] = true
 	}
 
-	hasReturn := false
 	if node.Body != nil {
 		for _, s := range node.Body.Statements {
-			_, ln := w.Walk(s)
-			switch ln.(type) {
-			case *ast.ReturnStatement:
-				hasReturn = true
-			}
+			w.Walk(s)
 		}
 	}
 
-	mustReturn := mustReturn(node.ReturnType)
-
-	if !hasReturn && mustReturn && !w.state.ingeneric {
-		w.addFatalf(
-			node.NameToken,
-			"`%v` is missing return",
-			node.Name,
-		)
-	}
+	w.checkReturns(node, node.NameToken)
 
 	w.warnUnusedVariables()
+	w.popAssignments()
+	w.state.fnenv = fnenv
 	w.env = environment.Open(w.env)
 }
 
@@ -1778,6 +2061,12 @@ func mustReturn(types ast.Types) bool {
 func (w *Walker) walkAnonymousFunctionLiteral(node *ast.FunctionLiteral) {
 	w.env = environment.Enclose(w.env, node.ReturnType)
 
+	// assignments within the body do not affect
+	// the enclosing environment until the function is called
+	fnenv := w.state.fnenv
+	w.state.fnenv = w.env
+	w.pushAssignments()
+
 	// we set the parameters in the environment
 	// and check that we don't have duplicates
 	paramsMap := make(map[string]bool)
@@ -1787,12 +2076,14 @@ func (w *Walker) walkAnonymousFunctionLiteral(node *ast.FunctionLiteral) {
 		}
 
 		paramsObject := environment.Variable{
-			Token:   p.Token,
-			Value:   p.Type,
-			CanMiss: p.Default == nil && p.Method,
-			Name:    p.Token.Value,
-			IsConst: false,
-			Used:    false,
+			Token:    p.Token,
+			Value:    p.Type,
+			CanMiss:  p.Default == nil && p.Method,
+			Name:     p.Token.Value,
+			IsConst:  false,
+			Used:     false,
+			HasValue: true,
+			IsParam:  true,
 		}
 
 		w.env.SetVariable(
@@ -1809,27 +2100,17 @@ func (w *Walker) walkAnonymousFunctionLiteral(node *ast.FunctionLiteral) {
 		paramsMap[p.Token.Value] = true
 	}
 
-	hasReturn := false
 	if node.Body != nil {
 		for _, s := range node.Body.Statements {
-			_, ln := w.Walk(s)
-			switch ln.(type) {
-			case *ast.ReturnStatement:
-				hasReturn = true
-			}
+			w.Walk(s)
 		}
 	}
 
-	mustReturn := mustReturn(node.ReturnType)
-
-	if !hasReturn && mustReturn && !w.state.ingeneric {
-		w.addFatalf(
-			node.Token,
-			"missing return",
-		)
-	}
+	w.checkReturns(node, node.Token)
 
 	w.warnUnusedVariables()
+	w.popAssignments()
+	w.state.fnenv = fnenv
 	w.env = environment.Open(w.env)
 }
 
@@ -2,8 +2,12 @@ package walker
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/vapourlang/vapour/config"
 	"github.com/vapourlang/vapour/diagnostics"
 	"github.com/vapourlang/vapour/environment"
 	"github.com/vapourlang/vapour/lexer"
@@ -403,6 +407,40 @@ y <- 2
 	w.testDiagnostics(t, expected)
 }
 
+func TestNamespaceArguments(t *testing.T) {
+	// the formals of the functions are fetched from R
+	if r.Offline() {
+		t.Skip("R is not available")
+	}
+
+	code := `
+base::paste0("a", "b", collapse = "")
+
+# should fail, too many arguments
+base::seq_len(1, 2)
+
+# should fail, unknown argument
+base::nchar(x = "a", wrong = 1)
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
 func TestFunction(t *testing.T) {
 	code := `
 func foo(n: int): int {
@@ -421,6 +459,7 @@ func foo(n: int): int {
   return x
 
   # should fail, returns does not exist
+  # should warn, unreachable
   return u
 }
 
@@ -451,6 +490,7 @@ lapply(1..10, (): int => {
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Warn},
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Info},
 		{Severity: diagnostics.Fatal},
@@ -459,33 +499,48 @@ lapply(1..10, (): int => {
 	w.testDiagnostics(t, expected)
 }
 
-func TestSquare(t *testing.T) {
-	code := `let x: int = (1,2,3)
-
-# we no longer check these
-x[2] = 3
-
-let zz: char = ("hello|world", "hello|again")
-let z: any = strsplit(zz[2], "\\|")[[1]]
+func TestControlFlow(t *testing.T) {
+	code := `
+func signOf(x: int = 1): int {
+  if (x > 0) {
+    return 1
+  } else {
+    return -1
+  }
+}
 
-x[1, 2] = 15
+# should fail, missing return when x is not positive
+func positive(x: int = 1): int {
+  if (x > 0) {
+    return x
+  }
+}
 
-x[[3]] = 15
+func check(x: int = 1): int {
+  if (x > 0) {
+    return x
+  }
 
-type xx: dataframe {
-  name: int
+  stop("negative")
 }
 
-let df: xx = xx(name = 1)
-
-# shoudl fail
-x$sth
+func loop(x: int = 1): int {
+  for(let i: int in 1..x) {
+    break
+    # should warn, unreachable
+    print(i)
+  }
 
-# should fail, wrong type
-df$name = "hello"
+  while(TRUE) {
+    return x
+  }
+}
 
-# should fail, not generic
-func (p: any) meth(): null {}
+func after(x: int = 1): int {
+  return x
+  # should warn, unreachable
+  print(x)
+}
 `
 
 	l := lexer.NewTest(code)
@@ -496,60 +551,69 @@ func (p: any) meth(): null {}
 	prog := p.Run()
 
 	w := New()
+
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Warn},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestBasic(t *testing.T) {
-	code := `let x: int = 1
-
-x = 2
-
-# should fail, it's already declared
-let x: char = "hello"
-
-type ids: struct {
-  int,
-	name: char
+func TestAssignment(t *testing.T) {
+	code := `
+func both(x: int = 1): int {
+  let y: int
+  if (x > 0) {
+    y = 1
+  } else {
+    y = 2
+  }
+  return y
 }
 
-# should fail, already defined
-type id: int
-
-# should fail, cannot find type
-let z: undefinedType = "hello"
-
-# should fail, different types
-let v: int = (10, "hello", NA)
-
-# should fail, type mismatch
-let wrongType: num = "hello"
-
-if(xx == 1) {
-	let x: int = 2
+func early(x: int = 1): int {
+  let y: int
+  if (x > 0) {
+    y = 1
+  } else {
+    return 0
+  }
+  return y
 }
 
-# should fail wrong types
-let x: int = "hello" + 2
+func one(x: int = 1): int {
+  let y: int
+  if (x > 0) {
+    y = 1
+  }
+  # should warn, might not be assigned
+  return y
+}
 
-# should fail, x is int, expression coerces to num
-x = 1 + 1.2
+func never(): int {
+  let y: int
+  # should fail, not assigned
+  return y
+}
 
-let Z: num = 1.2 + 3
+func loop(x: int = 1): int {
+  let y: int
+  for(let i: int in 1..x) {
+    y = i
+  }
+  # should warn, loop may not run
+  return y
+}
 
-# should fail, does not exist
-x = 2
+let z: int
 
-# should fail, does not exist
-uu = "char"
+func readGlobal(): int {
+  return z
+}
 `
 
 	l := lexer.NewTest(code)
@@ -559,56 +623,42 @@ uu = "char"
 
 	prog := p.Run()
 
-	if p.HasError() {
-		p.Errors().Print()
-		return
-	}
-
 	w := New()
 
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Warn},
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Warn},
-		{Severity: diagnostics.Info},
-		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestRecursiveTypes(t *testing.T) {
+func TestHoisting(t *testing.T) {
 	code := `
-type userid: int
+# used before they are declared
+let p: person = create("hello")
+greet(2)
 
-type user: struct {
-  userid,
-	name: char
+func create(name: char = "world"): person {
+  return person(name = name)
 }
 
-# should warn, might be missing
-func create(id: userid): user {
-  return user(id)
+func greet(n: int = 1): null {
+  print(n)
 }
 
-create(2)
-
-type person: struct {
-  char,
-	name: string
+type person: object {
+  name: char
 }
 
 # should fail, wrong type
-person(2)
+greet(p)
+
+# should fail, already defined
+func greet(): null {}
 `
 
 	l := lexer.NewTest(code)
@@ -623,34 +673,39 @@ person(2)
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Fatal},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestListTypes(t *testing.T) {
+func TestLint(t *testing.T) {
 	code := `
-type userid: int
-
-type user: object {
-	name: char
+#' @export
+func exported(x: int = 1): int {
+  let y: int = 1
+  if (x > 0) {
+    # should inform, shadows y
+    let y: int = 2
+    return y
+  }
+  return y
 }
 
-type users: []user
+# should inform, z is never used
+func helper(x: int = 1, z: int = 2): int {
+  return x
+}
 
-#should fail, wrong type
-let z: users = users(
-  user(name = "john"),
-	4
-)
+func unused(): null {
+  helper()
+}
 
-# should fail, named
-let w: users = users(
-  user(name = "john"),
-  x = user(name = "john"),
-)
+# should inform, never called nor exported
+func unusedCaller(): null {
+  unused()
+}
 `
 
 	l := lexer.NewTest(code)
@@ -660,39 +715,57 @@ let w: users = users(
 
 	prog := p.Run()
 
+	conf := config.Default()
+	conf.Lint.UnusedFunction = true
+
 	w := New()
+	w.Configure(conf)
 
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Info},
 		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
+
+	conf = config.Default()
+	conf.Lint.Shadow = false
+	conf.Lint.UnusedParameter = false
+
+	w = New()
+	w.Configure(conf)
+
+	w.Run(prog)
+
+	w.testDiagnostics(t, diagnostics.Diagnostics{})
 }
 
-func TestFor(t *testing.T) {
+func TestFormat(t *testing.T) {
 	code := `
-type userid: int
+let x: int = 1
+let y: num = 1.5
+let z: char = "hello"
 
-let x: userid = 10
+sprintf("%d %.2f %s %%", x, y, z)
+sprintf(fmt = "%2$s %1$d", x, z)
+sprintf("%*d", x, x)
+message(sprintf("%s", y))
+gettextf("%s: %d", z, x, domain = NA)
 
-for(let i: int in 1..x) {
-  print(i)
-}
+# should fail, %d expects int
+sprintf("%d", z)
 
-type x: struct {
-  int
-}
+# should fail, too few arguments
+sprintf("%s %s", z)
 
-let y: x = x(2)
+# should warn, unused argument
+sprintf("%s", z, x)
 
-# should fail, range has char..int
-for(let i: int in y) {
-  print(i)
-}
+# should fail, unknown verb
+sprintf("%y", x)
 `
 
 	l := lexer.NewTest(code)
@@ -708,30 +781,33 @@ for(let i: int in y) {
 
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Fatal},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestUnused(t *testing.T) {
+func TestDeclarations(t *testing.T) {
 	code := `
-# should warn, x is never used
-func foo(x: int): int {
-  return 1
-}
+let x: int = 1
 
-# should warn, does not exist
-print(y)
+let s: char = paste("a", x, sep = "-")
+let n: int = length(x)
+let total: int = sum(1, 2, 3, na.rm = TRUE)
 
-# should warn, might be missing
-func bar(x: int): int {
-  return x
-}
+# should fail, sep expects char
+paste("a", sep = 1)
 
-func baz(x: int): int { 
-  stopifnot(!missing(x))
-  return x
-}
+# should fail, too many arguments
+seq_len(1, 2)
+
+# should fail, unknown argument
+grepl("a", "b", global = TRUE)
+
+# should fail, length returns int
+let l: char = length(x)
 `
 
 	l := lexer.NewTest(code)
@@ -746,22 +822,24 @@ func baz(x: int): int {
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Info},
-		{Severity: diagnostics.Warn},
-		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestIncrement(t *testing.T) {
-	code := `
-let x: int = 1
+func TestDotsArgument(t *testing.T) {
+	code := `func join(sep: char = "", ...: char): char {
+  return sep
+}
 
-x += 1
+join("-", "a", "b")
 
-# should fail
-x += "aaah"
+# should fail, the argument is passed to ...
+join("-", "a", 1)
 `
 
 	l := lexer.NewTest(code)
@@ -780,34 +858,593 @@ x += "aaah"
 	}
 
 	w.testDiagnostics(t, expected)
-}
 
-func TestReal(t *testing.T) {
-	code := `
-type lst: list {
-  int | num
+	if !strings.Contains(w.Errors()[0].Message, "(passed to ...)") {
+		t.Fatalf("expected the argument passed to ..., got %v", w.Errors()[0].Message)
+	}
 }
 
-# should fail, multiple types
-type mat: matrix {
-  int | num
-}
+func TestSquare(t *testing.T) {
+	code := `let x: int = (1,2,3)
 
-# should fail, wrong arg name
-@matrix(nrow = 2, ncol = 4, wrong = true)
-type matty: matrix {
-  int
-}
-`
+# we no longer check these
+x[2] = 3
 
-	l := lexer.NewTest(code)
+let zz: char = ("hello|world", "hello|again")
+let z: any = strsplit(zz[2], "\\|")[[1]]
 
-	l.Run()
-	p := parser.New(l)
+x[1, 2] = 15
 
-	prog := p.Run()
+x[[3]] = 15
 
-	w := New()
+type xx: dataframe {
+  name: int
+}
+
+let df: xx = xx(name = 1)
+
+# shoudl fail
+x$sth
+
+# should fail, wrong type
+df$name = "hello"
+
+# should fail, not generic
+func (p: any) meth(): null {}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestBasic(t *testing.T) {
+	code := `let x: int = 1
+
+x = 2
+
+# should fail, it's already declared
+let x: char = "hello"
+
+type ids: struct {
+  int,
+	name: char
+}
+
+# should fail, already defined
+type id: int
+
+# should fail, cannot find type
+let z: undefinedType = "hello"
+
+# should fail, different types
+let v: int = (10, "hello", NA)
+
+# should fail, type mismatch
+let wrongType: num = "hello"
+
+if(xx == 1) {
+	let x: int = 2
+}
+
+# should fail wrong types
+let x: int = "hello" + 2
+
+# should fail, x is int, expression coerces to num
+x = 1 + 1.2
+
+let Z: num = 1.2 + 3
+
+# should fail, does not exist
+x = 2
+
+# should fail, does not exist
+uu = "char"
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	if p.HasError() {
+		p.Errors().Print()
+		return
+	}
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Warn},
+		// x shadows the top-level x
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Info},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	code := `
+type userid: int
+
+type user: struct {
+  userid,
+	name: char
+}
+
+# should warn, might be missing
+func create(id: userid): user {
+  return user(id)
+}
+
+create(2)
+
+type person: struct {
+  char,
+	name: string
+}
+
+# should fail, wrong type
+person(2)
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Fatal},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestListTypes(t *testing.T) {
+	code := `
+type userid: int
+
+type user: object {
+	name: char
+}
+
+type users: []user
+
+#should fail, wrong type
+let z: users = users(
+  user(name = "john"),
+	4
+)
+
+# should fail, named
+let w: users = users(
+  user(name = "john"),
+  x = user(name = "john"),
+)
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestFor(t *testing.T) {
+	code := `
+type userid: int
+
+let x: userid = 10
+
+for(let i: int in 1..x) {
+  print(i)
+}
+
+type x: struct {
+  int
+}
+
+let y: x = x(2)
+
+# should fail, range has char..int
+for(let i: int in y) {
+  print(i)
+}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestUnused(t *testing.T) {
+	code := `
+# should warn, x is never used
+func foo(x: int): int {
+  return 1
+}
+
+# should warn, does not exist
+print(y)
+
+# should warn, might be missing
+func bar(x: int): int {
+  return x
+}
+
+func baz(x: int): int { 
+  stopifnot(!missing(x))
+  return x
+}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Warn},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestIncrement(t *testing.T) {
+	code := `
+let x: int = 1
+
+x += 1
+
+# should fail
+x += "aaah"
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestReal(t *testing.T) {
+	code := `
+type lst: list {
+  int | num
+}
+
+# should fail, multiple types
+type mat: matrix {
+  int | num
+}
+
+# should fail, wrong arg name
+@matrix(nrow = 2, ncol = 4, wrong = true)
+type matty: matrix {
+  int
+}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Info},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestFactor(t *testing.T) {
+	code := `
+# should error, wrong arg
+@factor(wrong = TRUE)
+type fac: factor {
+  int
+}
+
+# should error, multiple types
+type fct: factor {
+  int | num
+}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestSignature(t *testing.T) {
+	code := `
+type math: func(int, int) int
+
+func apply_math(x: int = 2, y: int = 2, cb: math): int {
+  return cb(x, y)
+}
+
+func multiply(x: int = 2, y: int = 1): int {
+  return x * y
+}
+
+apply_math(1, 2, multiply)
+
+apply_math(1, 2, (x: int, y: int): int => {
+  return x + y
+})
+
+# should fail, wrong signatures
+apply_math(1, 2, (x: int): int => {
+  return x + 1
+})
+
+func zz(x: int = 2): num {
+  return x + 2.1
+}
+
+apply_math(1, 2, zz)
+
+apply_math(1, 2, (x: int, y: char): int => {
+  return x + 1
+})
+
+func foo(x: int): math {
+  return zz
+}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestMethodCall(t *testing.T) {
+	code := `
+type rules: object {
+  selector: char,
+  rule: char
+}
+
+type linne: object {
+  css: char,
+  rules: []rules
+}
+
+#' @export
+func create(): linne {
+  return linne()
+}
+
+#' @export
+func(l: linne) addRule(selector: char, ...: char): linne {
+  l$rules <- append(l$rules, rule(selector = selector, rule = ""))
+  return l
+}
+
+# error, already defined
+func(l: linne) addRule(): linne {
+  return l
+}
+
+# error, wrong type
+addRule("wrongType", "hello")
+
+# error, no args
+addRule()
+
+let l: linne = create()
+addRule(l, "hello")
+
+# should fail, method on any
+func(l: any) addRule(): null {}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestTypeInfix(t *testing.T) {
+	code := `
+let x: int = 10
+
+# cannot use $ on int
+# wrong unknow on x
+x$wrong = 2
+
+type person: object {
+  name: char
+}
+
+let p: person = person()
+
+# should fail, wrong type
+p$name = 2
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Fatal},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestType(t *testing.T) {
+	code := `
+# should fail, duplicated attribute
+type person: object {
+  name: char,
+	name: int
+}
+
+# should fail, multiple types
+type fct: factor { num | int }
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
 
 	w.Run(prog)
 
@@ -815,24 +1452,26 @@ type matty: matrix {
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Info},
-		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestFactor(t *testing.T) {
+func TestDate(t *testing.T) {
 	code := `
-# should error, wrong arg
-@factor(wrong = TRUE)
-type fac: factor {
-  int
-}
+type dt: date = as.Date("2022-02-01")
 
-# should error, multiple types
-type fct: factor {
-  int | num
+let x: dt = date("2022-02-01")
+
+type person: object {
+  dob: date
 }
+
+# should fail wrong type
+let p: person = person(dob = 2)
+
+# should fail wrong type
+p$dob = 1
 `
 
 	l := lexer.NewTest(code)
@@ -849,48 +1488,59 @@ type fct: factor {
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestSignature(t *testing.T) {
+func TestReturn(t *testing.T) {
 	code := `
-type math: func(int, int) int
+# should fail, missing return
+func foo(x: int): int {}
 
-func apply_math(x: int = 2, y: int = 2, cb: math): int {
-  return cb(x, y)
-}
+func (x: int) foo(y: char): null {}
 
-func multiply(x: int = 2, y: int = 1): int {
-  return x * y
+# should fail, wrong return type
+func bar(x: int): int {
+  return "hello"
 }
 
-apply_math(1, 2, multiply)
+func baz(): any {}
+`
 
-apply_math(1, 2, (x: int, y: int): int => {
-  return x + y
-})
+	l := lexer.NewTest(code)
 
-# should fail, wrong signatures
-apply_math(1, 2, (x: int): int => {
-  return x + 1
-})
+	l.Run()
+	p := parser.New(l)
 
-func zz(x: int = 2): num {
-  return x + 2.1
-}
+	prog := p.Run()
 
-apply_math(1, 2, zz)
+	w := New()
 
-apply_math(1, 2, (x: int, y: char): int => {
-  return x + 1
-})
+	w.Run(prog)
 
-func foo(x: int): math {
-  return zz
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
+	}
+
+	w.testDiagnostics(t, expected)
 }
+
+func TestDecorators(t *testing.T) {
+	code := `
+@generic
+func (p: person) foo(x: int): any
+
+# fail already defined
+@generic
+func (p: any) foo(x: int): any
+
+@generic
+func (p: any) bar(x: int): person
 `
 
 	l := lexer.NewTest(code)
@@ -906,54 +1556,96 @@ func foo(x: int): math {
 
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Info},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestMethodCall(t *testing.T) {
+func TestLibrary(t *testing.T) {
 	code := `
-type rules: object {
-  selector: char,
-  rule: char
-}
+library(something)
 
-type linne: object {
-  css: char,
-  rules: []rules
+require(package)
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Hint},
+		{Severity: diagnostics.Hint},
+	}
+
+	w.testDiagnostics(t, expected)
 }
 
-#' @export
-func create(): linne {
-  return linne()
+func TestAccessor(t *testing.T) {
+	code := `
+let x: any = 1
+
+# should WORK
+x["hello"]
+x[["hello"]]
+x$hello
+print(x$hello)
+
+let globals: any = new.env(env = parent.env(), hash = TRUE)
+
+let y: int = (1 ,2 ,3)
+y = y[3]
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{}
+
+	w.testDiagnostics(t, expected)
 }
 
-#' @export
-func(l: linne) addRule(selector: char, ...: char): linne {
-  l$rules <- append(l$rules, rule(selector = selector, rule = ""))
-  return l
+func TestEnvironment(t *testing.T) {
+	code := `
+let z: int = 1
+
+func addz(n: int = 1, y: int = 2): int {
+	if(n == 1){
+		return NA
+	}
+
+	return n + y + z
 }
 
-# error, already defined
-func(l: linne) addRule(): linne {
-  return l
+# should fail, comparing wrong types
+if (1 == "hello") {
+  print("1")
 }
 
-# error, wrong type
-addRule("wrongType", "hello")
+if (1 > 2.1) {
+  print("1")
+}
 
-# error, no args
-addRule()
+const c: int = 1
 
-let l: linne = create()
-addRule(l, "hello")
+# should fail, is constant
+c = 2
 
-# should fail, method on any
-func(l: any) addRule(): null {}
+print(c)
 `
 
 	l := lexer.NewTest(code)
@@ -968,10 +1660,38 @@ func(l: any) addRule(): null {}
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Warn},
-		{Severity: diagnostics.Warn},
 		{Severity: diagnostics.Info},
 		{Severity: diagnostics.Fatal},
+	}
+
+	w.testDiagnostics(t, expected)
+}
+
+func TestTypeImport(t *testing.T) {
+	code := `
+let x: vape::user = vape::user(id = 1)
+
+let y: vape::user = vape::user(id = "char")
+let w: vape::user = vape::user(wrong = 2)
+
+type custom: object {
+  user: vape::user
+}
+`
+
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	environment.SetLibrary(r.LibPath())
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Info},
@@ -980,22 +1700,44 @@ func(l: any) addRule(): null {}
 	w.testDiagnostics(t, expected)
 }
 
-func TestTypeInfix(t *testing.T) {
+func TestTypeNA(t *testing.T) {
 	code := `
-let x: int = 10
+let x: int = 2
 
-# cannot use $ on int
-# wrong unknow on x
-x$wrong = 2
+x = NA
+`
 
-type person: object {
-  name: char
+	l := lexer.NewTest(code)
+
+	l.Run()
+	p := parser.New(l)
+
+	prog := p.Run()
+
+	environment.SetLibrary(r.LibPath())
+	w := New()
+
+	w.Run(prog)
+
+	expected := diagnostics.Diagnostics{}
+
+	w.testDiagnostics(t, expected)
 }
 
-let p: person = person()
+func TestTypeEnvironment(t *testing.T) {
+	code := `
+type e: environment {
+  name: char,
+	x: int
+}
+
+e(name = "hello")
+
+# should fail, unknown attribute
+e(z = 2)
 
 # should fail, wrong type
-p$name = 2
+e(x = true)
 `
 
 	l := lexer.NewTest(code)
@@ -1005,6 +1747,7 @@ p$name = 2
 
 	prog := p.Run()
 
+	environment.SetLibrary(r.LibPath())
 	w := New()
 
 	w.Run(prog)
@@ -1012,22 +1755,18 @@ p$name = 2
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestType(t *testing.T) {
+func TestSymbols(t *testing.T) {
 	code := `
-# should fail, duplicated attribute
-type person: object {
-  name: char,
-	name: int
-}
+# should fail, type does not exist
+let foo: bar = baz
 
-# should fail, multiple types
-type fct: factor { num | int }
+# should fail, baz not found
+foo = baz
 `
 
 	l := lexer.NewTest(code)
@@ -1037,36 +1776,33 @@ type fct: factor { num | int }
 
 	prog := p.Run()
 
+	environment.SetLibrary(r.LibPath())
 	w := New()
 
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Warn},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestDate(t *testing.T) {
-	code := `
-type dt: date = as.Date("2022-02-01")
-
-let x: dt = date("2022-02-01")
-
-type person: object {
-  dob: date
-}
+func TestOffline(t *testing.T) {
+	code := `let x: char = "hello"
 
-# should fail wrong type
-let p: person = person(dob = 2)
+# should hint once, R is not available
+dplyr::filter(x)
+dplyr::select(x)
 
-# should fail wrong type
-p$dob = 1
+# base functions come from the snapshot
+print(Reduce(paste0, x))
 `
 
+	defer r.SetOffline(r.Offline())
+	r.SetOffline(true)
+
 	l := lexer.NewTest(code)
 
 	l.Run()
@@ -1079,28 +1815,56 @@ p$dob = 1
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Hint},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestReturn(t *testing.T) {
-	code := `
-# should fail, missing return
-func foo(x: int): int {}
+func TestPackageDeclarations(t *testing.T) {
+	lib := t.TempDir()
+	err := os.MkdirAll(filepath.Join(lib, "mypkg"), 0755)
 
-func (x: int) foo(y: char): null {}
+	if err != nil {
+		t.Fatal(err)
+	}
 
-# should fail, wrong return type
-func bar(x: int): int {
-  return "hello"
+	err = os.WriteFile(
+		filepath.Join(lib, "mypkg", "types.vp"),
+		[]byte(`type user: object {
+  name: char
 }
 
-func baz(): any {}
+func add_one(x: int): int {}
+
+func new_user(name: char): user {}
+`),
+		0644,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := `library(mypkg)
+
+# should fail, expects int
+add_one("a")
+
+let x: int = mypkg::add_one(1)
+
+# should fail, expects int
+mypkg::add_one("a")
+
+let u: mypkg::user = mypkg::new_user("john")
+
+print(x)
+print(u)
 `
 
+	defer r.SetOffline(r.Offline())
+	r.SetOffline(true)
+
 	l := lexer.NewTest(code)
 
 	l.Run()
@@ -1108,32 +1872,61 @@ func baz(): any {}
 
 	prog := p.Run()
 
+	environment.SetLibrary([]string{lib})
+	defer environment.SetLibrary(nil)
+
 	w := New()
 
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Hint},
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Info},
-		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Hint},
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestDecorators(t *testing.T) {
-	code := `
-@generic
-func (p: person) foo(x: int): any
+func TestGenerateTypes(t *testing.T) {
+	code := `type userid: int
 
-# fail already defined
-@generic
-func (p: any) foo(x: int): any
+@class(person, list)
+type user: object {
+  id: userid,
+  name: char
+}
+
+@matrix(nrow = 2, ncol = 2)
+type grid: matrix { num }
+
+#' @export
+func create(id: userid, name: char = "anon"): user {
+  return user(id = id, name = name)
+}
+
+#' @export
+func first(users: people::[]user, n: int = -1): people::[]user {
+  return users
+}
+
+func internal(x: int): int {
+  return x
+}
 
+#' @export
+func (u: user) describe(prefix: char = "user"): char {
+  return paste0(prefix, u$name)
+}
+
+func (u: user) hidden(): char {
+  return u$name
+}
+
+#' @export
 @generic
-func (p: any) bar(x: int): person
+func (x: any) greet(): char
 `
 
 	l := lexer.NewTest(code)
@@ -1147,100 +1940,107 @@ func (p: any) bar(x: int): person
 
 	w.Run(prog)
 
-	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Fatal},
-	}
+	expected := `@matrix(nrow = 2, ncol = 2)
+type grid: matrix { num }
 
-	w.testDiagnostics(t, expected)
+@class(person, list)
+type user: object {
+	id: userid,
+	name: char
 }
 
-func TestLibrary(t *testing.T) {
-	code := `
-library(something)
+type userid: int
 
-require(package)
-`
+func create(id: userid, name: char = "anon"): user {}
 
-	l := lexer.NewTest(code)
+func first(users: people::[]user, n: int = -1): people::[]user {}
 
-	l.Run()
-	p := parser.New(l)
+func (u: user) describe(prefix: char = "user"): char {}
 
-	prog := p.Run()
+@generic
+func (x: any) greet(): char
+`
 
-	w := New()
+	generated := w.Env().GenerateTypes().String()
 
-	w.Run(prog)
+	if generated != expected {
+		t.Fatalf("expected:\n%v\ngot:\n%v", expected, generated)
+	}
 
-	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Hint},
-		{Severity: diagnostics.Hint},
+	// read back by another package
+	lib := t.TempDir()
+	err := os.MkdirAll(filepath.Join(lib, "people"), 0755)
+
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	w.testDiagnostics(t, expected)
-}
+	err = os.WriteFile(filepath.Join(lib, "people", "types.vp"), []byte(generated), 0644)
 
-func TestAccessor(t *testing.T) {
-	code := `
-let x: any = 1
+	if err != nil {
+		t.Fatal(err)
+	}
 
-# should WORK
-x["hello"]
-x[["hello"]]
-x$hello
-print(x$hello)
+	code = `let u: people::user = people::create(1)
 
-let globals: any = new.env(env = parent.env(), hash = TRUE)
+# should fail, expects userid
+people::create("a")
 
-let y: int = (1 ,2 ,3)
-y = y[3]
+print(u)
 `
 
-	l := lexer.NewTest(code)
+	defer r.SetOffline(r.Offline())
+	r.SetOffline(true)
+
+	environment.SetLibrary([]string{lib})
+	defer environment.SetLibrary(nil)
+
+	l = lexer.NewTest(code)
 
 	l.Run()
-	p := parser.New(l)
+	p = parser.New(l)
 
-	prog := p.Run()
+	prog = p.Run()
 
-	w := New()
+	w = New()
 
 	w.Run(prog)
 
-	expected := diagnostics.Diagnostics{}
+	expectedDiagnostics := diagnostics.Diagnostics{
+		{Severity: diagnostics.Hint},
+		{Severity: diagnostics.Fatal},
+	}
 
-	w.testDiagnostics(t, expected)
+	w.testDiagnostics(t, expectedDiagnostics)
 }
 
-func TestEnvironment(t *testing.T) {
-	code := `
-let z: int = 1
-
-func addz(n: int = 1, y: int = 2): int {
-	if(n == 1){
-		return NA
-	}
+func TestTypeRegistry(t *testing.T) {
+	registry := t.TempDir()
 
-	return n + y + z
-}
+	err := os.WriteFile(
+		filepath.Join(registry, "dplyr.vp"),
+		[]byte(`func n_distinct(x: any, na.rm: bool = FALSE): int {}
+`),
+		0644,
+	)
 
-# should fail, comparing wrong types
-if (1 == "hello") {
-  print("1")
-}
+	if err != nil {
+		t.Fatal(err)
+	}
 
-if (1 > 2.1) {
-  print("1")
-}
+	code := `library(dplyr)
 
-const c: int = 1
+let n: int = n_distinct(c(1, 2))
 
-# should fail, is constant
-c = 2
+# should fail, expects bool
+n_distinct(c(1, 2), na.rm = "yes")
 
-print(c)
+print(n)
 `
 
+	environment.SetRegistries([]string{registry})
+	defer environment.SetRegistries(nil)
+
 	l := lexer.NewTest(code)
 
 	l.Run()
@@ -1253,23 +2053,53 @@ print(c)
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Info},
+		{Severity: diagnostics.Hint},
 		{Severity: diagnostics.Fatal},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestTypeImport(t *testing.T) {
-	code := `
-let x: vape::user = vape::user(id = 1)
+func TestRSource(t *testing.T) {
+	dir := t.TempDir()
 
-let y: vape::user = vape::user(id = "char")
-let w: vape::user = vape::user(wrong = 2)
+	err := os.WriteFile(
+		filepath.Join(dir, "helpers.R"),
+		[]byte(`scale_to <- function(x, to = 1) {
+  x / max(x) * to
+}
+`),
+		0644,
+	)
 
-type custom: object {
-  user: vape::user
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generated files are skipped
+	err = os.WriteFile(
+		filepath.Join(dir, "vapour.R"),
+		[]byte("# GENERATED BY VAPOUR\n# DO NOT EDIT\ngenerated <- function(x) x\n"),
+		0644,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := `let x: num = 2
+
+scale_to(x, to = 10)
+
+# should fail, argument does not exist
+scale_to(x, wrong = 10)
+
+# generated files are not loaded, no conflict
+func generated(x: num = 0): num {
+  return x
 }
+
+print(generated(x))
 `
 
 	l := lexer.NewTest(code)
@@ -1279,87 +2109,117 @@ type custom: object {
 
 	prog := p.Run()
 
-	environment.SetLibrary(r.LibPath())
 	w := New()
+	w.Env().LoadRSource(dir)
 
 	w.Run(prog)
 
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
 }
 
-func TestTypeNA(t *testing.T) {
-	code := `
-let x: int = 2
+func TestSkip(t *testing.T) {
+	files := lexer.Files{
+		{
+			Path: "b.vp",
+			Content: []byte(`let limit: int = 10
 
-x = NA
-`
+# should fail, but the file is skipped
+let z: char = 1
 
-	l := lexer.NewTest(code)
+helper(2)
+`),
+		},
+		{
+			Path: "a.vp",
+			Content: []byte(`func helper(x: int = 1): int {
+  return x + limit
+}
+
+func forgotten(): null {
+  print(1)
+}
+`),
+		},
+	}
+
+	l := lexer.New(files)
 
 	l.Run()
 	p := parser.New(l)
 
 	prog := p.Run()
 
-	environment.SetLibrary(r.LibPath())
+	conf := config.Default()
+	conf.Lint.UnusedFunction = true
+
 	w := New()
+	w.Configure(conf)
+	w.Skip(map[string]bool{"b.vp": true}, l.Items)
 
-	w.Run(prog)
+	w.Walk(prog)
 
-	expected := diagnostics.Diagnostics{}
+	// forgotten is never used, helper is called in b.vp
+	expected := diagnostics.Diagnostics{
+		{Severity: diagnostics.Info},
+	}
 
 	w.testDiagnostics(t, expected)
-}
 
-func TestTypeEnvironment(t *testing.T) {
-	code := `
-type e: environment {
-  name: char,
-	x: int
+	if len(w.StatementErrors()) != 0 {
+		t.Fatalf("expected no diagnostics on statements, got %v", w.StatementErrors())
+	}
 }
 
-e(name = "hello")
-
-# should fail, unknown attribute
-e(z = 2)
-
-# should fail, wrong type
-e(x = true)
-`
+func TestSkipAssigned(t *testing.T) {
+	files := lexer.Files{
+		{
+			Path: "a.vp",
+			Content: []byte(`let cfg: int
+cfg = 2
+`),
+		},
+		{
+			Path: "b.vp",
+			Content: []byte(`print(cfg)
+`),
+		},
+	}
 
-	l := lexer.NewTest(code)
+	l := lexer.New(files)
 
 	l.Run()
 	p := parser.New(l)
 
 	prog := p.Run()
 
-	environment.SetLibrary(r.LibPath())
 	w := New()
+	w.Skip(map[string]bool{"a.vp": true}, l.Items)
 
-	w.Run(prog)
+	w.Walk(prog)
 
-	expected := diagnostics.Diagnostics{
-		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Fatal},
-	}
+	// cfg is assigned in a.vp, which passed the checks
+	w.testDiagnostics(t, diagnostics.Diagnostics{})
+}
 
-	w.testDiagnostics(t, expected)
+func TestBodiesOrder(t *testing.T) {
+	code := `func first(x: int = 1): int {
+  let y: char = 1
+  print(y)
+  return x
 }
 
-func TestSymbols(t *testing.T) {
-	code := `
-# should fail, type does not exist
-let foo: bar = baz
+let z: char = 2
 
-# should fail, baz not found
-foo = baz
+func second(x: int = 1): int {
+  let unused: int = 1
+  return x
+}
+
+print(z)
 `
 
 	l := lexer.NewTest(code)
@@ -1369,15 +2229,22 @@ foo = baz
 
 	prog := p.Run()
 
-	environment.SetLibrary(r.LibPath())
 	w := New()
+	w.Walk(prog)
 
-	w.Run(prog)
-
+	// bodies are checked concurrently, the diagnostics
+	// are reported in the order of the code
 	expected := diagnostics.Diagnostics{
 		{Severity: diagnostics.Fatal},
-		{Severity: diagnostics.Warn},
+		{Severity: diagnostics.Fatal},
+		{Severity: diagnostics.Info},
 	}
 
 	w.testDiagnostics(t, expected)
+
+	for i, line := range []int{1, 6, 9} {
+		if w.Errors()[i].Token.Line != line {
+			t.Fatalf("diagnostics %v: expected line %v, got %v", i, line, w.Errors()[i].Token.Line)
+		}
+	}
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vapourlang/vapour/cli"
+	"github.com/vapourlang/vapour/devtools"
+	"github.com/vapourlang/vapour/environment"
+	"github.com/vapourlang/vapour/r"
+)
+
+// interval between two looks at the files
+const watchInterval = 500 * time.Millisecond
+
+// changes closer than this are one change, e.g. saving all files
+const watchDebounce = 200 * time.Millisecond
+
+// watch checks and transpiles -indir whenever its vapour
+// files change, the global environment is built once so
+// R is not started for every run
+func (v *vapour) watch(conf cli.CLI) {
+	v.base = environment.NewGlobalEnvironment()
+
+	last := ""
+	for {
+		state := snapshot(*conf.Indir)
+
+		if state == last {
+			time.Sleep(watchInterval)
+			continue
+		}
+
+		// wait for the saves to end
+		for {
+			time.Sleep(watchDebounce)
+			next := snapshot(*conf.Indir)
+
+			if next == state {
+				break
+			}
+
+			state = next
+		}
+
+		// diagnostics replace those of the previous run
+		fmt.Print(cli.Clear)
+		fmt.Printf("%v checking %v\n", time.Now().Format("15:04:05"), *conf.Indir)
+
+		// e.g. a file deleted while it was read, the next change retries
+		ok, err := v.transpile(conf)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		devtools.Run(ok, conf)
+
+		// the process is stopped with ctrl+c, never returns
+		r.SaveCache()
+
+		fmt.Println(cli.Gray + "watching for changes, press ctrl+c to stop" + cli.Reset)
+
+		// the run may write vapour files, e.g. -types
+		last = snapshot(*conf.Indir)
+	}
+}
+
+// snapshot describes the vapour files of the directory,
+// it changes when one is added, removed or modified
+func snapshot(root string) string {
+	var out strings.Builder
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".vp" {
+			return nil
+		}
+
+		info, err := d.Info()
+
+		if err != nil {
+			return nil
+		}
+
+		fmt.Fprintf(&out, "%v %v %v\n", path, info.Size(), info.ModTime().UnixNano())
+
+		return nil
+	})
+
+	return out.String()
+}